@@ -29,7 +29,7 @@ CODEGEN_PKG=/Users/xunyujiang/workspace/sourcecode/code-generator
 #                  instead of the $GOPATH directly. For normal projects this can be dropped.
 bash "${CODEGEN_PKG}"/generate-groups.sh "deepcopy,client,informer,lister" \
   github.com/istudies/k8s-operator/app-controller/pkg/generated github.com/istudies/k8s-operator/app-controller/pkg/apis \
-  appcontroller:v1 \
+  appcontroller:v1,v2 \
   --output-base "$(dirname "${BASH_SOURCE[0]}")/../../../../../" \
   --go-header-file "${SCRIPT_ROOT}"/hack/boilerplate.go.txt
 
@@ -9,19 +9,85 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/istudies/k8s-operator/app-controller/pkg/controller"
 	appclient "github.com/istudies/k8s-operator/app-controller/pkg/generated/clientset/versioned"
 	"github.com/istudies/k8s-operator/app-controller/pkg/generated/informers/externalversions"
+	applister "github.com/istudies/k8s-operator/app-controller/pkg/generated/listers/appcontroller/v1"
+	"github.com/istudies/k8s-operator/app-controller/pkg/metrics"
+	"github.com/istudies/k8s-operator/app-controller/pkg/webhook"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/rand"
 	"k8s.io/client-go/informers"
 	internalclient "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
 )
 
 func main() {
+	var fieldManager string
+	var forceConflicts bool
+	var enableLeaderElection bool
+	var leaseName, leaseNamespace string
+	var leaseDuration, renewDeadline, retryPeriod time.Duration
+	var metricsAddr string
+	var workers uint
+	var drainTimeout time.Duration
+	var pruneDryRun bool
+	var namespaces, appSelector string
+	var webhookAddr, webhookCertDir string
+	var immutableChildNames bool
+	flag.StringVar(&fieldManager, "field-manager", controller.DefaultFieldManager,
+		"The field manager used to server-side apply deployments, services and ingresses.")
+	flag.BoolVar(&forceConflicts, "force-conflicts", true,
+		"Take over fields owned by other managers when applying. "+
+			"When disabled, conflicting applies fail and are retried.")
+	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
+		"Enable leader election for app controller. "+
+			"Enabling this will ensure there is only one active app controller.")
+	flag.StringVar(&leaseName, "leader-elect-lease-name", "app-controller",
+		"The name of the Lease object used for leader election.")
+	flag.StringVar(&leaseNamespace, "leader-elect-lease-namespace", defaultLeaseNamespace(),
+		"The namespace of the Lease object used for leader election.")
+	flag.DurationVar(&leaseDuration, "leader-elect-lease-duration", 15*time.Second,
+		"The duration non-leader candidates wait before trying to take over an unrenewed lease.")
+	flag.DurationVar(&renewDeadline, "leader-elect-renew-deadline", 10*time.Second,
+		"The duration the leader retries refreshing leadership before giving it up.")
+	flag.DurationVar(&retryPeriod, "leader-elect-retry-period", 2*time.Second,
+		"The duration candidates wait between tries of acquiring or renewing leadership.")
+	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080",
+		"The address the metric endpoint binds to. Set to 0 to disable it.")
+	flag.UintVar(&workers, "workers", 5, "The number of apps reconciled concurrently.")
+	flag.DurationVar(&drainTimeout, "drain-timeout", controller.DefaultDrainTimeout,
+		"The duration in-flight apps get to finish reconciling on shut down.")
+	flag.BoolVar(&pruneDryRun, "prune-dry-run", false,
+		"Only log the children left behind by renames instead of deleting them. "+
+			"Use it to check what gets pruned before enabling deletion.")
+	flag.StringVar(&namespaces, "namespaces", "",
+		"Comma separated namespaces to watch. Empty watches all namespaces, "+
+			"otherwise Roles in the watched namespaces are enough.")
+	flag.StringVar(&appSelector, "app-selector", "",
+		"Label selector restricting the Apps reconciled, e.g. team=payments. Empty selects all Apps.")
+	flag.StringVar(&webhookAddr, "webhook-bind-address", "0",
+		"The address the admission webhooks and the App conversion webhook bind to, e.g. :9443. Set to 0 to disable them.")
+	flag.StringVar(&webhookCertDir, "webhook-cert-dir", webhook.DefaultCertDir,
+		"The directory holding tls.crt and tls.key the webhook is served with.")
+	flag.BoolVar(&immutableChildNames, "webhook-immutable-child-names", false,
+		"Deny updates renaming the deployment, service or ingress of an App instead of pruning the old children.")
+	flag.Parse()
+
 	// out-of-cluster
 	config, err := clientcmd.BuildConfigFromFlags("", clientcmd.RecommendedHomeFile)
 	if err != nil {
@@ -41,28 +107,233 @@ func main() {
 	if err != nil {
 		log.Fatalln(err)
 	}
-	// new shared informer factory
-	internalFactory := informers.NewSharedInformerFactory(internalClient, time.Second*30)
-	appFactory := externalversions.NewSharedInformerFactory(appClient, time.Second*30)
+	if _, err := labels.Parse(appSelector); err != nil {
+		log.Fatalf("invalid --app-selector: %v\n", err)
+	}
+	watchNamespaces := splitNamespaces(namespaces)
+
+	// one pair of shared informer factories and one app controller per watched namespace
+	var internalFactories []informers.SharedInformerFactory
+	var appFactories []externalversions.SharedInformerFactory
+	var appControllers []appControllerRunner
+	var appListers []applister.AppLister
+	for _, namespace := range watchNamespaces {
+		internalFactory := informers.NewSharedInformerFactoryWithOptions(internalClient, time.Second*30,
+			informers.WithNamespace(namespace))
+		// children carry the controllerBy label rather than the labels of their app and are not filtered
+		appFactory := externalversions.NewSharedInformerFactoryWithOptions(appClient, time.Second*30,
+			externalversions.WithNamespace(namespace),
+			externalversions.WithTweakListOptions(func(options *metav1.ListOptions) {
+				options.LabelSelector = appSelector
+			}))
+
+		opts := []controller.Option{
+			controller.WithFieldManager(fieldManager),
+			controller.WithForceConflicts(forceConflicts),
+			controller.WithDrainTimeout(drainTimeout),
+			controller.WithPruneDryRun(pruneDryRun),
+		}
+		if namespace != metav1.NamespaceAll {
+			opts = append(opts, controller.WithQueueName(controller.DefaultQueueName+"-"+namespace))
+		}
+		// construct app controller
+		appController := controller.NewAppController(
+			internalClient,
+			appClient,
+			internalFactory.Apps().V1().Deployments(),
+			internalFactory.Apps().V1().StatefulSets(),
+			internalFactory.Batch().V1().Jobs(),
+			internalFactory.Batch().V1().CronJobs(),
+			internalFactory.Core().V1().Services(),
+			internalFactory.Networking().V1().Ingresses(),
+			internalFactory.Autoscaling().V2().HorizontalPodAutoscalers(),
+			internalFactory.Policy().V1().PodDisruptionBudgets(),
+			internalFactory.Core().V1().ConfigMaps(),
+			internalFactory.Core().V1().Secrets(),
+			appFactory.Appcontroller().V1().Apps(),
+			opts...,
+		)
+
+		internalFactories = append(internalFactories, internalFactory)
+		appFactories = append(appFactories, appFactory)
+		appControllers = append(appControllers, appController)
+		appListers = append(appListers, appFactory.Appcontroller().V1().Apps().Lister())
+	}
 
-	// construct app controller
-	appController := controller.NewAppController(
-		internalClient,
-		appClient,
-		internalFactory.Apps().V1().Deployments(),
-		internalFactory.Core().V1().Services(),
-		internalFactory.Networking().V1().Ingresses(),
-		appFactory.Appcontroller().V1().Apps(),
-	)
+	// metrics
+	metrics.RegisterManagedObjects("appcontroller_managed_apps", "Number of Apps watched by the app controller", func() int {
+		count := 0
+		for _, appLister := range appListers {
+			apps, err := appLister.List(labels.Everything())
+			if err != nil {
+				return 0
+			}
+			count += len(apps)
+		}
+		return count
+	})
+	if metricsAddr != "0" {
+		go func() {
+			if err := metrics.Serve(metricsAddr); err != nil {
+				log.Fatalln(err)
+			}
+		}()
+	}
+
+	// admission and conversion webhooks, served by every replica whether or not it leads
+	if webhookAddr != "0" {
+		go func() {
+			validator := webhook.NewValidatingHandler(webhook.WithImmutableChildNames(immutableChildNames))
+			if err := webhook.Serve(webhookAddr, webhookCertDir, validator, webhook.NewMutatingHandler(), webhook.NewConversionHandler()); err != nil {
+				log.Fatalln(err)
+			}
+		}()
+	}
+
+	// stop on pod termination
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
 
 	// start shared informer factory
-	stopCh := make(chan struct{})
-	internalFactory.Start(stopCh)
-	appFactory.Start(stopCh)
+	for i := range watchNamespaces {
+		internalFactories[i].Start(ctx.Done())
+		appFactories[i].Start(ctx.Done())
+	}
+	for i := range watchNamespaces {
+		internalFactories[i].WaitForCacheSync(ctx.Done())
+		appFactories[i].WaitForCacheSync(ctx.Done())
+	}
 
-	internalFactory.WaitForCacheSync(stopCh)
-	appFactory.WaitForCacheSync(stopCh)
+	if !enableLeaderElection {
+		// run app controller
+		runAppControllers(ctx, appControllers, uint32(workers))
+		return
+	}
 
-	// run app controller
-	appController.Run(5, stopCh)
+	// only the leader runs the workers, standby replicas keep their caches warm
+	id, err := os.Hostname()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	id = id + "_" + rand.String(8)
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      leaseName,
+			Namespace: leaseNamespace,
+		},
+		Client: internalClient.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: id,
+		},
+	}
+	// the workers run in the goroutine of OnStartedLeading, which may still be starting when the lease is lost.
+	// once stopping is set no workers start anymore, so waiting on running covers every one that did.
+	var mu sync.Mutex
+	var running sync.WaitGroup
+	stopping := false
+	waitForWorkers := func() {
+		mu.Lock()
+		stopping = true
+		mu.Unlock()
+		done := make(chan struct{})
+		go func() {
+			running.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(drainTimeout + 5*time.Second):
+			log.Printf("workers did not stop within %s\n", drainTimeout)
+		}
+	}
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		ReleaseOnCancel: true,
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   renewDeadline,
+		RetryPeriod:     retryPeriod,
+		Name:            leaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				mu.Lock()
+				if stopping {
+					mu.Unlock()
+					return
+				}
+				running.Add(1)
+				mu.Unlock()
+				defer running.Done()
+				log.Printf("%s acquired lease %s/%s, starting workers\n", id, leaseNamespace, leaseName)
+				// workers stop as soon as the leadership context is cancelled
+				runAppControllers(ctx, appControllers, uint32(workers))
+			},
+			OnStoppedLeading: func() {
+				if ctx.Err() != nil {
+					// shutting down, main waits for the workers to drain
+					return
+				}
+				// informer caches and in-flight state are not reusable after losing the lease, restart clean.
+				// stop everything, give the workers their drain timeout to finish in-flight apps, then exit.
+				log.Printf("%s lost lease %s/%s, exiting\n", id, leaseNamespace, leaseName)
+				cancel()
+				waitForWorkers()
+				os.Exit(1)
+			},
+			OnNewLeader: func(identity string) {
+				if identity != id {
+					log.Printf("new leader elected: %s\n", identity)
+				}
+			},
+		},
+	})
+	waitForWorkers()
+}
+
+// defaultLeaseNamespace returns the namespace the controller runs in, falling back to default out of cluster
+func defaultLeaseNamespace() string {
+	if ns := os.Getenv("POD_NAMESPACE"); ns != "" {
+		return ns
+	}
+	if data, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace"); err == nil {
+		if ns := strings.TrimSpace(string(data)); ns != "" {
+			return ns
+		}
+	}
+	return metav1.NamespaceDefault
+}
+
+// appControllerRunner runs the workers of an app controller until ctx is cancelled
+type appControllerRunner interface {
+	Run(ctx context.Context, workerNum uint32)
+}
+
+// runAppControllers runs every controller with workers workers each and waits for all of them to stop
+func runAppControllers(ctx context.Context, appControllers []appControllerRunner, workers uint32) {
+	var wg sync.WaitGroup
+	for _, appController := range appControllers {
+		wg.Add(1)
+		go func(appController appControllerRunner) {
+			defer wg.Done()
+			appController.Run(ctx, workers)
+		}(appController)
+	}
+	wg.Wait()
+}
+
+// splitNamespaces parses the --namespaces flag, an empty list watches all namespaces
+func splitNamespaces(namespaces string) []string {
+	var result []string
+	seen := map[string]bool{}
+	for _, namespace := range strings.Split(namespaces, ",") {
+		namespace = strings.TrimSpace(namespace)
+		if namespace == "" || seen[namespace] {
+			continue
+		}
+		seen[namespace] = true
+		result = append(result, namespace)
+	}
+	if len(result) == 0 {
+		return []string{metav1.NamespaceAll}
+	}
+	return result
 }
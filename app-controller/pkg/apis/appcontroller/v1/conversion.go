@@ -0,0 +1,4 @@
+package v1
+
+// Hub marks v1, the storage version, as the hub every other version of App converts through
+func (*App) Hub() {}
@@ -0,0 +1,55 @@
+package v1
+
+import (
+	"strings"
+
+	core "k8s.io/api/core/v1"
+)
+
+// SetDefaults_App fills the fields a minimal App may leave out: child names derived from the app name,
+// the workload kind, the image pull policy and the service ports. Replicas and port protocols are defaulted by the CRD schema.
+func SetDefaults_App(app *App) {
+	if app.Spec.Deployment.Name == "" {
+		app.Spec.Deployment.Name = app.Name + "-deploy"
+	}
+	if app.Spec.Service.Name == "" {
+		app.Spec.Service.Name = app.Name + "-svc"
+	}
+	if app.Spec.Ingress.Name == "" {
+		app.Spec.Ingress.Name = app.Name + "-ingress"
+	}
+	if app.Spec.Deployment.WorkloadKind == "" {
+		app.Spec.Deployment.WorkloadKind = DeploymentWorkloadKind
+	}
+	if app.Spec.Deployment.ImagePullPolicy == "" {
+		app.Spec.Deployment.ImagePullPolicy = defaultImagePullPolicy(app.Spec.Deployment.Image)
+	}
+
+	if app.Spec.Service.Enabled && len(app.Spec.Service.Ports) == 0 {
+		app.Spec.Service.Ports = []ServicePort{{Protocol: core.ProtocolTCP, Port: 80, TargetPort: 80}}
+	}
+	for i := range app.Spec.Service.Ports {
+		port := &app.Spec.Service.Ports[i]
+		if port.Protocol == "" {
+			port.Protocol = core.ProtocolTCP
+		}
+		if port.TargetPort == 0 {
+			port.TargetPort = port.Port
+		}
+	}
+}
+
+// defaultImagePullPolicy mirrors the api server: Always for untagged and :latest images, IfNotPresent otherwise
+func defaultImagePullPolicy(image string) core.PullPolicy {
+	if strings.Contains(image, "@") {
+		return core.PullIfNotPresent
+	}
+	tag := ""
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		tag = image[i+1:]
+	}
+	if tag == "" || tag == "latest" {
+		return core.PullAlways
+	}
+	return core.PullIfNotPresent
+}
@@ -1,43 +1,486 @@
 package v1
 
-import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+import (
+	autoscaling "k8s.io/api/autoscaling/v2"
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	net "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
 
 // generated command by: type-scaffold --kind App > pkg/apis/appcontroller/v1/types.go
 
+// WorkloadKind is the kind of workload running the app pods
+// +kubebuilder:validation:Enum=Deployment;StatefulSet;Job;CronJob
+type WorkloadKind string
+
+const (
+	// DeploymentWorkloadKind runs the app pods in a Deployment
+	DeploymentWorkloadKind WorkloadKind = "Deployment"
+	// StatefulSetWorkloadKind runs the app pods in a StatefulSet, with stable identities and a volume claim per pod
+	StatefulSetWorkloadKind WorkloadKind = "StatefulSet"
+	// JobWorkloadKind runs the app pods to completion once in a Job
+	JobWorkloadKind WorkloadKind = "Job"
+	// CronJobWorkloadKind runs the app pods to completion on a schedule, in the Jobs of a CronJob
+	CronJobWorkloadKind WorkloadKind = "CronJob"
+)
+
+type BatchObj struct {
+	// cron schedule of the CronJob workload kind, required for it. e.g.: */5 * * * *
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
+	// runs of the CronJob workload kind due while the previous one is still running: Allow, Forbid or Replace. default: Allow
+	// +optional
+	// +kubebuilder:validation:Enum=Allow;Forbid;Replace
+	ConcurrencyPolicy batch.ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
+	// pod failures before a job is marked failed. default: 6
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	BackoffLimit *int32 `json:"backoffLimit,omitempty"`
+	// successful jobs of the CronJob workload kind kept for inspection. default: 3
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	SuccessfulJobsHistoryLimit *int32 `json:"successfulJobsHistoryLimit,omitempty"`
+	// failed jobs of the CronJob workload kind kept for inspection. default: 1
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	FailedJobsHistoryLimit *int32 `json:"failedJobsHistoryLimit,omitempty"`
+}
+
 type DeploymentObj struct {
-	// deployment name
+	// deployment name. default: <app name>-deploy
+	// +optional
 	Name string `json:"name"`
+	// workload kind: Deployment, StatefulSet, Job or CronJob. a StatefulSet is governed by a generated headless service
+	// named <deployment name>-headless. default: Deployment
+	// +optional
+	// +kubebuilder:default=Deployment
+	WorkloadKind WorkloadKind `json:"workloadKind,omitempty"`
 	// deployment image. e.g.: nginx:latest
 	Image string `json:"image"`
-	// deployment replications
+	// deployment replications. default: 1
+	// +optional
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=0
 	Replicas int32 `json:"replicas"`
+	// image pull policy: Always, IfNotPresent or Never. default: Always for :latest images, IfNotPresent otherwise
+	// +optional
+	// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+	ImagePullPolicy core.PullPolicy `json:"imagePullPolicy,omitempty"`
+	// entrypoint of the container. default: the image entrypoint
+	// +optional
+	Command []string `json:"command,omitempty"`
+	// arguments to the entrypoint. default: the image cmd
+	// +optional
+	Args []string `json:"args,omitempty"`
+	// environment variables, values may come from secrets, configmaps or pod fields
+	// +optional
+	Env []core.EnvVar `json:"env,omitempty"`
+	// compute resource requests and limits of the container
+	// +optional
+	Resources core.ResourceRequirements `json:"resources,omitempty"`
+	// probe restarting the container when it fails
+	// +optional
+	LivenessProbe *core.Probe `json:"livenessProbe,omitempty"`
+	// probe removing the pod from service endpoints when it fails
+	// +optional
+	ReadinessProbe *core.Probe `json:"readinessProbe,omitempty"`
+	// probe holding off the other probes until the container has started
+	// +optional
+	StartupProbe *core.Probe `json:"startupProbe,omitempty"`
+	// pod volumes, mounted into the container through volumeMounts
+	// +optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	Volumes []core.Volume `json:"volumes,omitempty"`
+	// volumes mounted into the container
+	// +optional
+	VolumeMounts []core.VolumeMount `json:"volumeMounts,omitempty"`
+	// configmaps in the app namespace whose changes roll out the deployment
+	// +optional
+	ConfigMaps []string `json:"configMaps,omitempty"`
+	// secrets in the app namespace whose changes roll out the deployment
+	// +optional
+	Secrets []string `json:"secrets,omitempty"`
+	// persistent volume claims created for every pod, only for the StatefulSet workload kind.
+	// the claims are mounted into the container through volumeMounts by their name
+	// +optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	VolumeClaimTemplates []core.PersistentVolumeClaim `json:"volumeClaimTemplates,omitempty"`
+	// job options of the Job and CronJob workload kinds, whose jobs run replicas pods to completion in parallel
+	// +optional
+	Batch *BatchObj `json:"batch,omitempty"`
+}
+
+type ServicePort struct {
+	// port name, required when more than one port is declared
+	// +optional
+	Name string `json:"name,omitempty"`
+	// port protocol: TCP, UDP or SCTP. default: TCP
+	// +optional
+	// +kubebuilder:default=TCP
+	// +kubebuilder:validation:Enum=TCP;UDP;SCTP
+	Protocol core.Protocol `json:"protocol,omitempty"`
+	// port exposed by the service
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+	// port the container listens on. default: same as port
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	TargetPort int32 `json:"targetPort,omitempty"`
+	// node port, only for NodePort and LoadBalancer services. default: allocated by the cluster
+	// +optional
+	NodePort int32 `json:"nodePort,omitempty"`
 }
 
 type ServiceObj struct {
 	// enabled service
 	Enabled bool `json:"enabled"`
-	// service name
+	// service name. default: <app name>-svc
+	// +optional
 	Name string `json:"name"`
+	// service type: ClusterIP, NodePort or LoadBalancer. default: ClusterIP
+	// +optional
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	Type core.ServiceType `json:"type,omitempty"`
+	// headless service without cluster ip, only for ClusterIP services
+	// +optional
+	Headless bool `json:"headless,omitempty"`
+	// session affinity: None or ClientIP. default: None
+	// +optional
+	// +kubebuilder:validation:Enum=None;ClientIP
+	SessionAffinity core.ServiceAffinity `json:"sessionAffinity,omitempty"`
+	// seconds of ClientIP session stickiness. default: 10800
+	// +optional
+	SessionAffinityTimeoutSeconds *int32 `json:"sessionAffinityTimeoutSeconds,omitempty"`
+	// service ports, also exposed by the deployment's container. default: TCP 80 to 80
+	// +optional
+	Ports []ServicePort `json:"ports,omitempty"`
+}
+
+type IngressPath struct {
+	// url path, must start with /. default: /
+	// +optional
+	Path string `json:"path,omitempty"`
+	// path type: Prefix, Exact or ImplementationSpecific. default: Prefix
+	// +optional
+	// +kubebuilder:validation:Enum=Prefix;Exact;ImplementationSpecific
+	PathType net.PathType `json:"pathType,omitempty"`
+	// backend service name. default: the app service
+	// +optional
+	ServiceName string `json:"serviceName,omitempty"`
+	// backend service port name or number. default: the first TCP port of the app service
+	// +optional
+	ServicePort *intstr.IntOrString `json:"servicePort,omitempty"`
+}
+
+type IngressRule struct {
+	// host name, wildcard like *.example.com is allowed. empty matches all hosts
+	// +optional
+	Host string `json:"host,omitempty"`
+	// paths routed for the host. default: / to the app service
+	// +optional
+	Paths []IngressPath `json:"paths,omitempty"`
+}
+
+type IngressTLS struct {
+	// hosts covered by the certificate
+	// +optional
+	Hosts []string `json:"hosts,omitempty"`
+	// secret holding the certificate and key. empty uses the ingress controller's default certificate
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
 }
 
 type IngressObj struct {
 	// enabled ingress
 	Enabled bool `json:"enabled"`
-	// ingress name
+	// ingress name. default: <app name>-ingress
+	// +optional
 	Name string `json:"name"`
+	// ingress class name, an empty string leaves it to the cluster default class. default: nginx
+	// +optional
+	ClassName *string `json:"className,omitempty"`
+	// annotations copied to the ingress, e.g. for the ingress controller
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// host rules. default: testing.com with / to the app service
+	// +optional
+	Rules []IngressRule `json:"rules,omitempty"`
+	// tls blocks referencing certificate secrets
+	// +optional
+	TLS []IngressTLS `json:"tls,omitempty"`
+}
+
+type AutoscalingObj struct {
+	// enabled autoscaling, the deployment replicas are then left to a HorizontalPodAutoscaler named after the deployment
+	Enabled bool `json:"enabled"`
+	// lower limit of replicas. default: 1
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+	// upper limit of replicas, required when autoscaling is enabled
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxReplicas int32 `json:"maxReplicas,omitempty"`
+	// target average cpu utilization, in percent of the cpu requests
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	TargetCPUUtilizationPercentage *int32 `json:"targetCPUUtilizationPercentage,omitempty"`
+	// target average memory utilization, in percent of the memory requests
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	TargetMemoryUtilizationPercentage *int32 `json:"targetMemoryUtilizationPercentage,omitempty"`
+	// additional autoscaling/v2 metric targets, e.g. pods, object or external metrics.
+	// default: 80% cpu utilization when no target is set
+	// +optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	Metrics []autoscaling.MetricSpec `json:"metrics,omitempty"`
+}
+
+type DisruptionBudgetObj struct {
+	// enabled disruption budget, a PodDisruptionBudget named after the deployment limits voluntary evictions of its pods
+	Enabled bool `json:"enabled"`
+	// pods that must stay available during evictions, a number or a percentage. e.g.: 2 or 50%
+	// exclusive with maxUnavailable. default: 1 when neither is set
+	// +optional
+	// +kubebuilder:validation:XIntOrString
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+	// pods that may be unavailable during evictions, a number or a percentage. e.g.: 1 or 25%
+	// +optional
+	// +kubebuilder:validation:XIntOrString
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// RolloutStrategyType decides how an image change of the deployment reaches the pods
+// +kubebuilder:validation:Enum=RollingUpdate;Canary;BlueGreen
+type RolloutStrategyType string
+
+const (
+	// RollingUpdateStrategyType leaves image changes to the rolling update of the deployment
+	RollingUpdateStrategyType RolloutStrategyType = "RollingUpdate"
+	// CanaryStrategyType shifts traffic to a canary deployment step by step before promoting the image
+	CanaryStrategyType RolloutStrategyType = "Canary"
+	// BlueGreenStrategyType brings up a preview deployment and switches the service to it on promotion
+	BlueGreenStrategyType RolloutStrategyType = "BlueGreen"
+)
+
+type CanaryStep struct {
+	// percent of the ingress traffic routed to the canary
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	Weight int32 `json:"weight"`
+	// how long to wait before the next step, once the canary is available. default: wait for promotion
+	// +optional
+	Pause *metav1.Duration `json:"pause,omitempty"`
+}
+
+type CanaryStrategy struct {
+	// replicas of the canary deployment. default: 1
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	Replicas *int32 `json:"replicas,omitempty"`
+	// traffic steps walked through before the new image is promoted
+	// +kubebuilder:validation:MinItems=1
+	Steps []CanaryStep `json:"steps"`
 }
 
+type BlueGreenStrategy struct {
+	// promote as soon as the preview deployment is available instead of waiting for promotion
+	// +optional
+	AutoPromote bool `json:"autoPromote,omitempty"`
+}
+
+type StrategyObj struct {
+	// strategy type: RollingUpdate, Canary or BlueGreen. default: RollingUpdate
+	// +optional
+	Type RolloutStrategyType `json:"type,omitempty"`
+	// canary steps, required for the Canary strategy
+	// +optional
+	Canary *CanaryStrategy `json:"canary,omitempty"`
+	// blue/green options of the BlueGreen strategy
+	// +optional
+	BlueGreen *BlueGreenStrategy `json:"blueGreen,omitempty"`
+}
+
+// DeletionPolicy decides what happens to the children when an App is deleted
+// +kubebuilder:validation:Enum=Delete;Orphan
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete deletes the deployment, service and ingress together with the app
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyOrphan leaves the children running and drops their owner reference
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+)
+
 // AppSpec defines the desired state of App
 type AppSpec struct {
 	Deployment DeploymentObj `json:"deployment"`
 	Service    ServiceObj    `json:"service"`
 	Ingress    IngressObj    `json:"ingress"`
+	// horizontal autoscaling of the deployment
+	// +optional
+	Autoscaling AutoscalingObj `json:"autoscaling,omitempty"`
+	// disruption budget of the deployment pods, e.g. against node drains evicting every replica at once
+	// +optional
+	DisruptionBudget DisruptionBudgetObj `json:"disruptionBudget,omitempty"`
+	// rollout strategy of image changes, promoted with the appcontroller.me/promote annotation
+	// and aborted with the appcontroller.me/abort annotation
+	// +optional
+	Strategy StrategyObj `json:"strategy,omitempty"`
+	// policy applied to children on app deletion, Delete or Orphan. default: Delete
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// stops the controller from writing to the children, e.g. while they are edited by hand during an incident.
+	// their drift is still reported in status, and resuming applies the spec again.
+	// the appcontroller.me/paused=true annotation pauses the app the same way
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+}
+
+// condition types reported in AppStatus.Conditions
+const (
+	// AppReady is true when every enabled child of the app is healthy
+	AppReady = "Ready"
+	// AppDeploymentAvailable mirrors the Available condition of the managed deployment,
+	// reports whether every replica of the managed statefulset is available, whether the managed job completed,
+	// or whether the last job of the managed cronjob succeeded
+	AppDeploymentAvailable = "DeploymentAvailable"
+	// AppServiceReady is true when the managed service exists
+	AppServiceReady = "ServiceReady"
+	// AppIngressReady is true when the managed ingress exists and has been given an address
+	AppIngressReady = "IngressReady"
+	// AppPaused is true while the app is paused and its children are left alone
+	AppPaused = "Paused"
+)
+
+// RolloutPhase is the state of an image rollout
+type RolloutPhase string
+
+const (
+	// RolloutHealthy means no rollout is in progress, the stable image is the spec image
+	RolloutHealthy RolloutPhase = "Healthy"
+	// RolloutProgressing means the new image is brought up or walks through the canary steps
+	RolloutProgressing RolloutPhase = "Progressing"
+	// RolloutPaused means the rollout waits for the appcontroller.me/promote annotation
+	RolloutPaused RolloutPhase = "Paused"
+	// RolloutPromoting means the new image is rolled out to the stable deployment
+	RolloutPromoting RolloutPhase = "Promoting"
+	// RolloutAborted means the new image was rejected, the stable image keeps serving until the spec changes
+	RolloutAborted RolloutPhase = "Aborted"
+)
+
+type RolloutStatus struct {
+	// rollout phase: Healthy, Progressing, Paused, Promoting or Aborted
+	Phase RolloutPhase `json:"phase"`
+	// image served by the stable deployment
+	// +optional
+	StableImage string `json:"stableImage,omitempty"`
+	// image being rolled out, empty when no rollout is in progress
+	// +optional
+	UpdatedImage string `json:"updatedImage,omitempty"`
+	// index of the current canary step
+	// +optional
+	CurrentStep int32 `json:"currentStep,omitempty"`
+	// percent of the ingress traffic currently routed to the canary
+	// +optional
+	CurrentWeight int32 `json:"currentWeight,omitempty"`
+	// when the pause of the current canary step started
+	// +optional
+	PauseStartTime *metav1.Time `json:"pauseStartTime,omitempty"`
+	// human readable details of the current phase
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ComponentStatus is the observed state of a component after the first one of an app written as v2
+type ComponentStatus struct {
+	// component name
+	Name string `json:"name"`
+	// observed replications of the workload
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+	// ready replications of the workload
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+	// name of the managed workload
+	// +optional
+	WorkloadName string `json:"workloadName,omitempty"`
+	// name of the managed service, empty when service is disabled
+	// +optional
+	ServiceName string `json:"serviceName,omitempty"`
+	// name of the managed ingress, empty when ingress is disabled
+	// +optional
+	IngressName string `json:"ingressName,omitempty"`
+	// label selector of the workload pods
+	// +optional
+	Selector string `json:"selector,omitempty"`
+	// last time a job of the CronJob workload kind was scheduled
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	// last time a job of the Job or CronJob workload kind completed successfully
+	// +optional
+	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
+	// last time a job of the Job or CronJob workload kind failed
+	// +optional
+	LastFailureTime *metav1.Time `json:"lastFailureTime,omitempty"`
 }
 
 // AppStatus defines the observed state of App.
 // It should always be reconstructable from the state of the cluster and/or outside world.
 type AppStatus struct {
-	// INSERT ADDITIONAL STATUS FIELDS -- observed state of cluster
+	// the most recent app generation observed by the controller
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// observed replications of the deployment, read by the scale subresource
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+	// ready replications of the deployment
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+	// name of the managed deployment, statefulset, job or cronjob
+	// +optional
+	DeploymentName string `json:"deploymentName,omitempty"`
+	// name of the managed service, empty when service is disabled
+	// +optional
+	ServiceName string `json:"serviceName,omitempty"`
+	// name of the managed ingress, empty when ingress is disabled
+	// +optional
+	IngressName string `json:"ingressName,omitempty"`
+	// state of the current image rollout, only reported for the Canary and BlueGreen strategies
+	// +optional
+	Rollout *RolloutStatus `json:"rollout,omitempty"`
+	// label selector of the deployment pods, in the string form used by the scale subresource
+	// +optional
+	Selector string `json:"selector,omitempty"`
+	// last time a job of the CronJob workload kind was scheduled
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	// last time a job of the Job or CronJob workload kind completed successfully
+	// +optional
+	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
+	// last time a job of the Job or CronJob workload kind failed
+	// +optional
+	LastFailureTime *metav1.Time `json:"lastFailureTime,omitempty"`
+	// observed state of the components after the first one of an app written as v2
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Components []ComponentStatus `json:"components,omitempty"`
+	// fields of the workload differing from the spec while the app is paused, e.g. deployment nginx-app-deploy: spec.replicas
+	// +optional
+	Drift []string `json:"drift,omitempty"`
+	// latest observations of the app's state
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 }
 
 // +genclient
@@ -45,6 +488,16 @@ type AppStatus struct {
 
 // App is the Schema for the apps API
 // +k8s:openapi-gen=true
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+// +kubebuilder:subresource:scale:specpath=.spec.deployment.replicas,statuspath=.status.replicas,selectorpath=.status.selector
+// +kubebuilder:printcolumn:name="Image",type=string,JSONPath=`.spec.deployment.image`
+// +kubebuilder:printcolumn:name="Desired",type=integer,JSONPath=`.spec.deployment.replicas`
+// +kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.readyReplicas`
+// +kubebuilder:printcolumn:name="Service",type=boolean,JSONPath=`.spec.service.enabled`
+// +kubebuilder:printcolumn:name="Ingress",type=boolean,JSONPath=`.spec.ingress.enabled`
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 type App struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
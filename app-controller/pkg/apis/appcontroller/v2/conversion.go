@@ -0,0 +1,394 @@
+package v2
+
+import (
+	"encoding/json"
+	"fmt"
+
+	v1 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v1"
+)
+
+// ComponentsAnnotation keeps on the v1 object what v1 cannot hold of a v2 app: the name of the first component
+// and the components after it, so that converting v2 -> v1 -> v2 loses nothing
+const ComponentsAnnotation = "appcontroller.me/v2-components"
+
+// DefaultComponentName names the component of an app created as v1
+const DefaultComponentName = "main"
+
+// components is the value of ComponentsAnnotation
+type components struct {
+	// name of the first component
+	Name string `json:"name"`
+	// components after the first one
+	Extra []Component `json:"extra,omitempty"`
+}
+
+// ExtraComponents returns the components after the first one that app keeps in ComponentsAnnotation
+func ExtraComponents(app *v1.App) ([]Component, error) {
+	data, ok := app.Annotations[ComponentsAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	var rest components
+	if err := json.Unmarshal([]byte(data), &rest); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", ComponentsAnnotation, err)
+	}
+	return rest.Extra, nil
+}
+
+// ComponentApp returns the v1 view of component, one of the extra components of app: a copy of app with the
+// spec of the component, whose children are named after the app and the component unless the component names them
+func ComponentApp(app *v1.App, component Component) *v1.App {
+	out := app.DeepCopy()
+	delete(out.Annotations, ComponentsAnnotation)
+	out.Spec = v1.AppSpec{
+		Deployment:       convertWorkloadToV1(component.Workload),
+		Service:          convertServiceToV1(component.Service),
+		Ingress:          convertIngressToV1(component.Ingress),
+		Autoscaling:      v1.AutoscalingObj(component.Autoscaling),
+		DisruptionBudget: v1.DisruptionBudgetObj(component.DisruptionBudget),
+		Strategy:         convertStrategyToV1(component.Strategy),
+		DeletionPolicy:   app.Spec.DeletionPolicy,
+		Paused:           app.Spec.Paused,
+	}
+	prefix := app.Name + "-" + component.Name
+	if out.Spec.Deployment.Name == "" {
+		out.Spec.Deployment.Name = prefix
+	}
+	if out.Spec.Service.Name == "" {
+		out.Spec.Service.Name = prefix + "-svc"
+	}
+	if out.Spec.Ingress.Name == "" {
+		out.Spec.Ingress.Name = prefix + "-ingress"
+	}
+	// the generation and conditions are those of the app, the rest of the status is the component's own
+	out.Status = v1.AppStatus{ObservedGeneration: app.Status.ObservedGeneration, Conditions: out.Status.Conditions}
+	for _, status := range app.Status.Components {
+		if status.Name == component.Name {
+			out.Status.LastScheduleTime = status.LastScheduleTime
+			out.Status.LastSuccessfulTime = status.LastSuccessfulTime
+			out.Status.LastFailureTime = status.LastFailureTime
+		}
+	}
+	return out
+}
+
+// ConvertTo converts src to the v1 hub. The first component becomes the deployment, service and ingress of dst,
+// the other components are kept in ComponentsAnnotation.
+func (src *App) ConvertTo(dst *v1.App) error {
+	in := src.DeepCopy()
+	dst.ObjectMeta = in.ObjectMeta
+	delete(dst.Annotations, ComponentsAnnotation)
+	dst.Spec = v1.AppSpec{DeletionPolicy: v1.DeletionPolicy(in.Spec.DeletionPolicy), Paused: in.Spec.Paused}
+	dst.Status = v1.AppStatus{
+		ObservedGeneration: in.Status.ObservedGeneration,
+		Rollout:            convertRolloutStatusToV1(in.Status.Rollout),
+		Drift:              in.Status.Drift,
+		Conditions:         in.Status.Conditions,
+	}
+	if len(in.Spec.Components) == 0 {
+		return nil
+	}
+
+	primary := in.Spec.Components[0]
+	dst.Spec.Deployment = convertWorkloadToV1(primary.Workload)
+	dst.Spec.Service = convertServiceToV1(primary.Service)
+	dst.Spec.Ingress = convertIngressToV1(primary.Ingress)
+	dst.Spec.Autoscaling = v1.AutoscalingObj(primary.Autoscaling)
+	dst.Spec.DisruptionBudget = v1.DisruptionBudgetObj(primary.DisruptionBudget)
+	dst.Spec.Strategy = convertStrategyToV1(primary.Strategy)
+
+	rest := components{Name: primary.Name, Extra: in.Spec.Components[1:]}
+	for _, status := range in.Status.Components {
+		if status.Name != primary.Name {
+			dst.Status.Components = append(dst.Status.Components, v1.ComponentStatus(status))
+			continue
+		}
+		dst.Status.Replicas = status.Replicas
+		dst.Status.ReadyReplicas = status.ReadyReplicas
+		dst.Status.DeploymentName = status.WorkloadName
+		dst.Status.ServiceName = status.ServiceName
+		dst.Status.IngressName = status.IngressName
+		dst.Status.Selector = status.Selector
+		dst.Status.LastScheduleTime = status.LastScheduleTime
+		dst.Status.LastSuccessfulTime = status.LastSuccessfulTime
+		dst.Status.LastFailureTime = status.LastFailureTime
+	}
+	// an app with a single default component round trips without the annotation
+	if rest.Name == DefaultComponentName && len(rest.Extra) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(rest)
+	if err != nil {
+		return fmt.Errorf("encode components of app %s/%s: %w", src.Namespace, src.Name, err)
+	}
+	if dst.Annotations == nil {
+		dst.Annotations = map[string]string{}
+	}
+	dst.Annotations[ComponentsAnnotation] = string(data)
+	return nil
+}
+
+// ConvertFrom converts the v1 hub src to dst, restoring the components kept in ComponentsAnnotation
+func (dst *App) ConvertFrom(src *v1.App) error {
+	in := src.DeepCopy()
+	dst.ObjectMeta = in.ObjectMeta
+	rest := components{Name: DefaultComponentName}
+	if data, ok := dst.Annotations[ComponentsAnnotation]; ok {
+		if err := json.Unmarshal([]byte(data), &rest); err != nil {
+			return fmt.Errorf("decode %s of app %s/%s: %w", ComponentsAnnotation, src.Namespace, src.Name, err)
+		}
+		if rest.Name == "" {
+			rest.Name = DefaultComponentName
+		}
+		delete(dst.Annotations, ComponentsAnnotation)
+		if len(dst.Annotations) == 0 {
+			dst.Annotations = nil
+		}
+	}
+
+	primary := Component{
+		Name:             rest.Name,
+		Workload:         convertWorkloadFromV1(in.Spec.Deployment),
+		Service:          convertServiceFromV1(in.Spec.Service),
+		Ingress:          convertIngressFromV1(in.Spec.Ingress),
+		Autoscaling:      AutoscalingObj(in.Spec.Autoscaling),
+		DisruptionBudget: DisruptionBudgetObj(in.Spec.DisruptionBudget),
+		Strategy:         convertStrategyFromV1(in.Spec.Strategy),
+	}
+	dst.Spec = AppSpec{
+		Components:     append([]Component{primary}, rest.Extra...),
+		DeletionPolicy: DeletionPolicy(in.Spec.DeletionPolicy),
+		Paused:         in.Spec.Paused,
+	}
+
+	dst.Status = AppStatus{
+		ObservedGeneration: in.Status.ObservedGeneration,
+		Rollout:            convertRolloutStatusFromV1(in.Status.Rollout),
+		Drift:              in.Status.Drift,
+		Conditions:         in.Status.Conditions,
+	}
+	status := ComponentStatus{
+		Name:               rest.Name,
+		Replicas:           in.Status.Replicas,
+		ReadyReplicas:      in.Status.ReadyReplicas,
+		WorkloadName:       in.Status.DeploymentName,
+		ServiceName:        in.Status.ServiceName,
+		IngressName:        in.Status.IngressName,
+		Selector:           in.Status.Selector,
+		LastScheduleTime:   in.Status.LastScheduleTime,
+		LastSuccessfulTime: in.Status.LastSuccessfulTime,
+		LastFailureTime:    in.Status.LastFailureTime,
+	}
+	// an app the controller has not reported on yet has no component status
+	if status != (ComponentStatus{Name: rest.Name}) {
+		dst.Status.Components = append(dst.Status.Components, status)
+	}
+	for _, status := range in.Status.Components {
+		dst.Status.Components = append(dst.Status.Components, ComponentStatus(status))
+	}
+	return nil
+}
+
+func convertWorkloadToV1(in WorkloadObj) v1.DeploymentObj {
+	return v1.DeploymentObj{
+		Name:                 in.Name,
+		WorkloadKind:         v1.WorkloadKind(in.Kind),
+		Image:                in.Image,
+		Replicas:             in.Replicas,
+		ImagePullPolicy:      in.ImagePullPolicy,
+		Command:              in.Command,
+		Args:                 in.Args,
+		Env:                  in.Env,
+		Resources:            in.Resources,
+		LivenessProbe:        in.LivenessProbe,
+		ReadinessProbe:       in.ReadinessProbe,
+		StartupProbe:         in.StartupProbe,
+		Volumes:              in.Volumes,
+		VolumeMounts:         in.VolumeMounts,
+		ConfigMaps:           in.ConfigMaps,
+		Secrets:              in.Secrets,
+		VolumeClaimTemplates: in.VolumeClaimTemplates,
+		Batch:                (*v1.BatchObj)(in.Batch),
+	}
+}
+
+func convertWorkloadFromV1(in v1.DeploymentObj) WorkloadObj {
+	return WorkloadObj{
+		Name:                 in.Name,
+		Kind:                 WorkloadKind(in.WorkloadKind),
+		Image:                in.Image,
+		Replicas:             in.Replicas,
+		ImagePullPolicy:      in.ImagePullPolicy,
+		Command:              in.Command,
+		Args:                 in.Args,
+		Env:                  in.Env,
+		Resources:            in.Resources,
+		LivenessProbe:        in.LivenessProbe,
+		ReadinessProbe:       in.ReadinessProbe,
+		StartupProbe:         in.StartupProbe,
+		Volumes:              in.Volumes,
+		VolumeMounts:         in.VolumeMounts,
+		ConfigMaps:           in.ConfigMaps,
+		Secrets:              in.Secrets,
+		VolumeClaimTemplates: in.VolumeClaimTemplates,
+		Batch:                (*BatchObj)(in.Batch),
+	}
+}
+
+func convertServiceToV1(in ServiceObj) v1.ServiceObj {
+	out := v1.ServiceObj{
+		Enabled:                       in.Enabled,
+		Name:                          in.Name,
+		Type:                          in.Type,
+		Headless:                      in.Headless,
+		SessionAffinity:               in.SessionAffinity,
+		SessionAffinityTimeoutSeconds: in.SessionAffinityTimeoutSeconds,
+	}
+	if in.Ports != nil {
+		out.Ports = make([]v1.ServicePort, len(in.Ports))
+		for i, port := range in.Ports {
+			out.Ports[i] = v1.ServicePort(port)
+		}
+	}
+	return out
+}
+
+func convertServiceFromV1(in v1.ServiceObj) ServiceObj {
+	out := ServiceObj{
+		Enabled:                       in.Enabled,
+		Name:                          in.Name,
+		Type:                          in.Type,
+		Headless:                      in.Headless,
+		SessionAffinity:               in.SessionAffinity,
+		SessionAffinityTimeoutSeconds: in.SessionAffinityTimeoutSeconds,
+	}
+	if in.Ports != nil {
+		out.Ports = make([]ServicePort, len(in.Ports))
+		for i, port := range in.Ports {
+			out.Ports[i] = ServicePort(port)
+		}
+	}
+	return out
+}
+
+func convertIngressToV1(in IngressObj) v1.IngressObj {
+	out := v1.IngressObj{
+		Enabled:     in.Enabled,
+		Name:        in.Name,
+		ClassName:   in.ClassName,
+		Annotations: in.Annotations,
+	}
+	if in.Routes != nil {
+		out.Rules = make([]v1.IngressRule, len(in.Routes))
+		for i, route := range in.Routes {
+			out.Rules[i].Host = route.Host
+			if route.Paths != nil {
+				out.Rules[i].Paths = make([]v1.IngressPath, len(route.Paths))
+				for j, path := range route.Paths {
+					out.Rules[i].Paths[j] = v1.IngressPath(path)
+				}
+			}
+		}
+	}
+	if in.TLS != nil {
+		out.TLS = make([]v1.IngressTLS, len(in.TLS))
+		for i, tls := range in.TLS {
+			out.TLS[i] = v1.IngressTLS(tls)
+		}
+	}
+	return out
+}
+
+func convertIngressFromV1(in v1.IngressObj) IngressObj {
+	out := IngressObj{
+		Enabled:     in.Enabled,
+		Name:        in.Name,
+		ClassName:   in.ClassName,
+		Annotations: in.Annotations,
+	}
+	if in.Rules != nil {
+		out.Routes = make([]Route, len(in.Rules))
+		for i, rule := range in.Rules {
+			out.Routes[i].Host = rule.Host
+			if rule.Paths != nil {
+				out.Routes[i].Paths = make([]RoutePath, len(rule.Paths))
+				for j, path := range rule.Paths {
+					out.Routes[i].Paths[j] = RoutePath(path)
+				}
+			}
+		}
+	}
+	if in.TLS != nil {
+		out.TLS = make([]IngressTLS, len(in.TLS))
+		for i, tls := range in.TLS {
+			out.TLS[i] = IngressTLS(tls)
+		}
+	}
+	return out
+}
+
+func convertStrategyToV1(in StrategyObj) v1.StrategyObj {
+	out := v1.StrategyObj{Type: v1.RolloutStrategyType(in.Type)}
+	if in.Canary != nil {
+		out.Canary = &v1.CanaryStrategy{Replicas: in.Canary.Replicas}
+		if in.Canary.Steps != nil {
+			out.Canary.Steps = make([]v1.CanaryStep, len(in.Canary.Steps))
+			for i, step := range in.Canary.Steps {
+				out.Canary.Steps[i] = v1.CanaryStep(step)
+			}
+		}
+	}
+	if in.BlueGreen != nil {
+		blueGreen := v1.BlueGreenStrategy(*in.BlueGreen)
+		out.BlueGreen = &blueGreen
+	}
+	return out
+}
+
+func convertStrategyFromV1(in v1.StrategyObj) StrategyObj {
+	out := StrategyObj{Type: RolloutStrategyType(in.Type)}
+	if in.Canary != nil {
+		out.Canary = &CanaryStrategy{Replicas: in.Canary.Replicas}
+		if in.Canary.Steps != nil {
+			out.Canary.Steps = make([]CanaryStep, len(in.Canary.Steps))
+			for i, step := range in.Canary.Steps {
+				out.Canary.Steps[i] = CanaryStep(step)
+			}
+		}
+	}
+	if in.BlueGreen != nil {
+		blueGreen := BlueGreenStrategy(*in.BlueGreen)
+		out.BlueGreen = &blueGreen
+	}
+	return out
+}
+
+func convertRolloutStatusToV1(in *RolloutStatus) *v1.RolloutStatus {
+	if in == nil {
+		return nil
+	}
+	return &v1.RolloutStatus{
+		Phase:          v1.RolloutPhase(in.Phase),
+		StableImage:    in.StableImage,
+		UpdatedImage:   in.UpdatedImage,
+		CurrentStep:    in.CurrentStep,
+		CurrentWeight:  in.CurrentWeight,
+		PauseStartTime: in.PauseStartTime,
+		Message:        in.Message,
+	}
+}
+
+func convertRolloutStatusFromV1(in *v1.RolloutStatus) *RolloutStatus {
+	if in == nil {
+		return nil
+	}
+	return &RolloutStatus{
+		Phase:          RolloutPhase(in.Phase),
+		StableImage:    in.StableImage,
+		UpdatedImage:   in.UpdatedImage,
+		CurrentStep:    in.CurrentStep,
+		CurrentWeight:  in.CurrentWeight,
+		PauseStartTime: in.PauseStartTime,
+		Message:        in.Message,
+	}
+}
@@ -0,0 +1,244 @@
+package v2
+
+import (
+	"testing"
+	"time"
+
+	v1 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v1"
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/diff"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func int32Ptr(i int32) *int32 { return &i }
+
+func stringPtr(s string) *string { return &s }
+
+// newV1App returns an app setting every field v1 has
+func newV1App() *v1.App {
+	port := intstr.FromString("http")
+	minAvailable := intstr.FromString("50%")
+	lastRun := metav1.NewTime(time.Date(2026, 10, 20, 3, 0, 0, 0, time.UTC))
+	return &v1.App{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "shop",
+			Namespace:   metav1.NamespaceDefault,
+			Generation:  3,
+			Annotations: map[string]string{"team": "checkout"},
+			Finalizers:  []string{"appcontroller.me/finalizer"},
+		},
+		Spec: v1.AppSpec{
+			Deployment: v1.DeploymentObj{
+				Name:            "shop-deploy",
+				WorkloadKind:    v1.DeploymentWorkloadKind,
+				Image:           "nginx:1.23",
+				Replicas:        3,
+				ImagePullPolicy: core.PullIfNotPresent,
+				Command:         []string{"nginx"},
+				Args:            []string{"-g", "daemon off;"},
+				Env:             []core.EnvVar{{Name: "MODE", Value: "production"}},
+				Resources: core.ResourceRequirements{
+					Requests: core.ResourceList{core.ResourceCPU: resource.MustParse("100m")},
+				},
+				ReadinessProbe: &core.Probe{ProbeHandler: core.ProbeHandler{
+					HTTPGet: &core.HTTPGetAction{Path: "/healthz", Port: intstr.FromInt(80)},
+				}},
+				Volumes:      []core.Volume{{Name: "config", VolumeSource: core.VolumeSource{EmptyDir: &core.EmptyDirVolumeSource{}}}},
+				VolumeMounts: []core.VolumeMount{{Name: "config", MountPath: "/etc/nginx/conf.d"}},
+				ConfigMaps:   []string{"shop-config"},
+				Secrets:      []string{"shop-secret"},
+				Batch:        &v1.BatchObj{ConcurrencyPolicy: batch.ForbidConcurrent, BackoffLimit: int32Ptr(2)},
+			},
+			Service: v1.ServiceObj{
+				Enabled:                       true,
+				Name:                          "shop-svc",
+				Type:                          core.ServiceTypeNodePort,
+				SessionAffinity:               core.ServiceAffinityClientIP,
+				SessionAffinityTimeoutSeconds: int32Ptr(600),
+				Ports:                         []v1.ServicePort{{Name: "http", Protocol: core.ProtocolTCP, Port: 80, TargetPort: 8080, NodePort: 30080}},
+			},
+			Ingress: v1.IngressObj{
+				Enabled:     true,
+				Name:        "shop-ingress",
+				ClassName:   stringPtr("nginx"),
+				Annotations: map[string]string{"nginx.ingress.kubernetes.io/rewrite-target": "/"},
+				Rules: []v1.IngressRule{{
+					Host:  "shop.example.com",
+					Paths: []v1.IngressPath{{Path: "/", PathType: "Prefix", ServiceName: "shop-svc", ServicePort: &port}},
+				}},
+				TLS: []v1.IngressTLS{{Hosts: []string{"shop.example.com"}, SecretName: "shop-tls"}},
+			},
+			Autoscaling: v1.AutoscalingObj{
+				Enabled:                        true,
+				MinReplicas:                    int32Ptr(2),
+				MaxReplicas:                    10,
+				TargetCPUUtilizationPercentage: int32Ptr(70),
+			},
+			DisruptionBudget: v1.DisruptionBudgetObj{Enabled: true, MinAvailable: &minAvailable},
+			Strategy: v1.StrategyObj{
+				Type: v1.CanaryStrategyType,
+				Canary: &v1.CanaryStrategy{
+					Replicas: int32Ptr(1),
+					Steps:    []v1.CanaryStep{{Weight: 20, Pause: &metav1.Duration{Duration: time.Minute}}, {Weight: 50}},
+				},
+				BlueGreen: &v1.BlueGreenStrategy{AutoPromote: true},
+			},
+			DeletionPolicy: v1.DeletionPolicyOrphan,
+			Paused:         true,
+		},
+		Status: v1.AppStatus{
+			ObservedGeneration: 3,
+			Replicas:           3,
+			ReadyReplicas:      2,
+			DeploymentName:     "shop-deploy",
+			ServiceName:        "shop-svc",
+			IngressName:        "shop-ingress",
+			Selector:           "app=shop,controller=shop",
+			LastSuccessfulTime: &lastRun,
+			LastFailureTime:    &lastRun,
+			Rollout: &v1.RolloutStatus{
+				Phase:         v1.RolloutPaused,
+				StableImage:   "nginx:1.22",
+				UpdatedImage:  "nginx:1.23",
+				CurrentWeight: 20,
+				Message:       "waiting for promotion",
+			},
+			Drift:      []string{"deployment shop-deploy: spec.replicas"},
+			Conditions: []metav1.Condition{{Type: v1.AppReady, Status: metav1.ConditionTrue, Reason: "ChildrenReady"}},
+		},
+	}
+}
+
+// newV2App returns an app with a web, a worker and a report component
+func newV2App() *App {
+	lastRun := metav1.NewTime(time.Date(2026, 10, 20, 3, 0, 0, 0, time.UTC))
+	return &App{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: metav1.NamespaceDefault},
+		Spec: AppSpec{
+			Components: []Component{
+				{
+					Name:     "web",
+					Workload: WorkloadObj{Name: "shop-web", Image: "nginx:1.23", Replicas: 2},
+					Service:  ServiceObj{Enabled: true, Name: "shop-web", Ports: []ServicePort{{Port: 80, TargetPort: 8080}}},
+					Ingress: IngressObj{Enabled: true, Name: "shop-web", Routes: []Route{{
+						Host:  "shop.example.com",
+						Paths: []RoutePath{{Path: "/"}},
+					}}},
+				},
+				{
+					Name: "worker",
+					Workload: WorkloadObj{
+						Name:     "shop-worker",
+						Kind:     StatefulSetWorkloadKind,
+						Image:    "shop/worker:1.0",
+						Replicas: 4,
+						Args:     []string{"--queue", "orders"},
+						VolumeClaimTemplates: []core.PersistentVolumeClaim{{
+							ObjectMeta: metav1.ObjectMeta{Name: "spool"},
+							Spec: core.PersistentVolumeClaimSpec{
+								AccessModes: []core.PersistentVolumeAccessMode{core.ReadWriteOnce},
+								Resources: core.ResourceRequirements{
+									Requests: core.ResourceList{core.ResourceStorage: resource.MustParse("1Gi")},
+								},
+							},
+						}},
+						VolumeMounts: []core.VolumeMount{{Name: "spool", MountPath: "/var/spool/worker"}},
+					},
+					Autoscaling: AutoscalingObj{Enabled: true, MaxReplicas: 8},
+				},
+				{
+					Name: "report",
+					Workload: WorkloadObj{
+						Name:  "shop-report",
+						Kind:  CronJobWorkloadKind,
+						Image: "shop/report:1.0",
+						Batch: &BatchObj{Schedule: "0 3 * * *", SuccessfulJobsHistoryLimit: int32Ptr(1)},
+					},
+				},
+			},
+		},
+		Status: AppStatus{
+			ObservedGeneration: 1,
+			Components: []ComponentStatus{
+				{Name: "web", Replicas: 2, ReadyReplicas: 2, WorkloadName: "shop-web", ServiceName: "shop-web", IngressName: "shop-web"},
+				{Name: "worker", Replicas: 4, ReadyReplicas: 1, WorkloadName: "shop-worker"},
+				{Name: "report", WorkloadName: "shop-report", LastScheduleTime: &lastRun, LastSuccessfulTime: &lastRun},
+			},
+		},
+	}
+}
+
+func TestRoundTripFromV1(t *testing.T) {
+	app := newV1App()
+
+	converted := &App{}
+	if err := converted.ConvertFrom(app); err != nil {
+		t.Fatalf("convert from v1: %v", err)
+	}
+	if len(converted.Spec.Components) != 1 || converted.Spec.Components[0].Name != DefaultComponentName {
+		t.Fatalf("expected a single %s component, got %+v", DefaultComponentName, converted.Spec.Components)
+	}
+	if routes := converted.Spec.Components[0].Ingress.Routes; len(routes) != 1 || routes[0].Host != "shop.example.com" {
+		t.Errorf("expected the ingress rules as routes, got %+v", routes)
+	}
+
+	back := &v1.App{}
+	if err := converted.ConvertTo(back); err != nil {
+		t.Fatalf("convert to v1: %v", err)
+	}
+	if !equality.Semantic.DeepEqual(app, back) {
+		t.Errorf("v1 -> v2 -> v1 changed the app:\n%s", diff.ObjectGoPrintSideBySide(app, back))
+	}
+}
+
+func TestRoundTripFromV2(t *testing.T) {
+	app := newV2App()
+
+	hub := &v1.App{}
+	if err := app.ConvertTo(hub); err != nil {
+		t.Fatalf("convert to v1: %v", err)
+	}
+	if hub.Spec.Deployment.Name != "shop-web" || hub.Status.DeploymentName != "shop-web" {
+		t.Errorf("expected the web component as the v1 deployment, got %q reported as %q",
+			hub.Spec.Deployment.Name, hub.Status.DeploymentName)
+	}
+	if _, ok := hub.Annotations[ComponentsAnnotation]; !ok {
+		t.Errorf("expected the worker component to be kept in %s", ComponentsAnnotation)
+	}
+
+	back := &App{}
+	if err := back.ConvertFrom(hub); err != nil {
+		t.Fatalf("convert from v1: %v", err)
+	}
+	if !equality.Semantic.DeepEqual(app, back) {
+		t.Errorf("v2 -> v1 -> v2 changed the app:\n%s", diff.ObjectGoPrintSideBySide(app, back))
+	}
+}
+
+func TestConvertsDefaultComponentWithoutAnnotation(t *testing.T) {
+	app := newV2App()
+	app.Spec.Components = app.Spec.Components[:1]
+	app.Spec.Components[0].Name = DefaultComponentName
+	app.Status = AppStatus{}
+
+	hub := &v1.App{}
+	if err := app.ConvertTo(hub); err != nil {
+		t.Fatalf("convert to v1: %v", err)
+	}
+	if hub.Annotations != nil {
+		t.Errorf("expected no annotation for a single default component, got %v", hub.Annotations)
+	}
+}
+
+func TestRejectsMalformedComponentsAnnotation(t *testing.T) {
+	app := newV1App()
+	app.Annotations[ComponentsAnnotation] = "{"
+
+	if err := (&App{}).ConvertFrom(app); err == nil {
+		t.Errorf("expected a malformed %s to fail the conversion", ComponentsAnnotation)
+	}
+}
@@ -0,0 +1,9 @@
+// +k8s:deepcopy-gen=package
+// +groupName=appcontroller.me
+package v2
+
+// v2 describes an app as a list of components, each with its own workload, service and routes.
+// v1 stays the storage version: every v2 object is converted through the v1 hub, see conversion.go.
+
+// gen crd yaml by: controller-gen crd paths=./... output:crd:dir=./manifest output:stdout
+// gen deep copy by: controller-gen object paths=./pkg/apis/appcontroller/v2/types.go
@@ -20,85 +20,211 @@ import (
 	appClient "github.com/istudies/k8s-operator/app-controller/pkg/generated/clientset/versioned"
 	appInformer "github.com/istudies/k8s-operator/app-controller/pkg/generated/informers/externalversions/appcontroller/v1"
 	applister "github.com/istudies/k8s-operator/app-controller/pkg/generated/listers/appcontroller/v1"
+	"github.com/istudies/k8s-operator/app-controller/pkg/metrics"
+	"github.com/istudies/k8s-operator/app-controller/pkg/validation"
 	deployapps "k8s.io/api/apps/v1"
 	core "k8s.io/api/core/v1"
 	net "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/runtime"
-	"k8s.io/apimachinery/pkg/util/wait"
 	deploymentInformer "k8s.io/client-go/informers/apps/v1"
+	autoscalingInformer "k8s.io/client-go/informers/autoscaling/v2"
+	batchInformer "k8s.io/client-go/informers/batch/v1"
 	coreInformer "k8s.io/client-go/informers/core/v1"
 	netInformer "k8s.io/client-go/informers/networking/v1"
-	"k8s.io/client-go/kubernetes"
+	policyInformer "k8s.io/client-go/informers/policy/v1"
 	internalclient "k8s.io/client-go/kubernetes"
 	deploylister "k8s.io/client-go/listers/apps/v1"
+	autoscalinglister "k8s.io/client-go/listers/autoscaling/v2"
+	batchlister "k8s.io/client-go/listers/batch/v1"
 	corelister "k8s.io/client-go/listers/core/v1"
 	netlister "k8s.io/client-go/listers/networking/v1"
+	policylister "k8s.io/client-go/listers/policy/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 )
 
 const controllerBy = "controllerBy"
 
-var once sync.Once
+// controllerName labels the metrics of this controller
+const controllerName = "app"
+
+// DefaultDrainTimeout is how long Run waits for in-flight keys on shut down
+const DefaultDrainTimeout = 30 * time.Second
+
+// DefaultQueueName names the work queue in the workqueue metrics
+const DefaultQueueName = "appControllerQueue"
+
+// WithQueueName sets the name of the work queue, to tell apart the queues of several controllers in one process
+func WithQueueName(name string) Option {
+	return func(c *appController) {
+		c.queueName = name
+	}
+}
+
+// WithDrainTimeout sets how long Run waits for in-flight keys on shut down
+func WithDrainTimeout(timeout time.Duration) Option {
+	return func(c *appController) {
+		c.drainTimeout = timeout
+	}
+}
 
 type appController struct {
-	internalClient   internalclient.Interface
-	appClient        appClient.Interface
-	deploymentLister deploylister.DeploymentLister
-	serviceLister    corelister.ServiceLister
-	ingressLister    netlister.IngressLister
-	appLister        applister.AppLister
-	queue            workqueue.RateLimitingInterface
-}
-
-func NewAppController(internalClient *kubernetes.Clientset, appClient *appClient.Clientset,
-	deployInformer deploymentInformer.DeploymentInformer, svcInformer coreInformer.ServiceInformer,
-	ingInformer netInformer.IngressInformer, appInformer appInformer.AppInformer) *appController {
-	var ctl appController
-	once.Do(func() {
-		ctl = appController{
-			internalClient:   internalClient,
-			appClient:        appClient,
-			deploymentLister: deployInformer.Lister(),
-			serviceLister:    svcInformer.Lister(),
-			ingressLister:    ingInformer.Lister(),
-			appLister:        appInformer.Lister(),
-			queue:            workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "appControllerQueue"),
-		}
+	internalClient    internalclient.Interface
+	appClient         appClient.Interface
+	deploymentLister  deploylister.DeploymentLister
+	statefulSetLister deploylister.StatefulSetLister
+	jobLister         batchlister.JobLister
+	cronJobLister     batchlister.CronJobLister
+	serviceLister     corelister.ServiceLister
+	ingressLister     netlister.IngressLister
+	hpaLister         autoscalinglister.HorizontalPodAutoscalerLister
+	pdbLister         policylister.PodDisruptionBudgetLister
+	configMapLister   corelister.ConfigMapLister
+	secretLister      corelister.SecretLister
+	appLister         applister.AppLister
+	queue             workqueue.RateLimitingInterface
+	recorder          record.EventRecorder
 
-		// event handler
-		deployInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
-			DeleteFunc: ctl.deleteDeploymentEvent,
-		})
-		svcInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
-			DeleteFunc: ctl.deleteSvcEvent,
-		})
-		ingInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
-			DeleteFunc: ctl.deleteIngressEvent,
-		})
-		appInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
-			AddFunc:    ctl.addAppEvent,
-			UpdateFunc: ctl.updateAppEvent,
-			DeleteFunc: ctl.deleteAppEvent,
-		})
+	queueName string
+
+	// server-side apply settings
+	fieldManager   string
+	forceConflicts bool
+	// how long Run waits for in-flight keys on shut down
+	drainTimeout time.Duration
+	// only log stale children instead of deleting them
+	pruneDryRun bool
+}
+
+// NewAppController builds an app controller and registers its event handlers on the given informers
+func NewAppController(internalClient internalclient.Interface, appClient appClient.Interface,
+	deployInformer deploymentInformer.DeploymentInformer, stsInformer deploymentInformer.StatefulSetInformer,
+	jobInformer batchInformer.JobInformer, cronJobInformer batchInformer.CronJobInformer,
+	svcInformer coreInformer.ServiceInformer, ingInformer netInformer.IngressInformer,
+	hpaInformer autoscalingInformer.HorizontalPodAutoscalerInformer, pdbInformer policyInformer.PodDisruptionBudgetInformer,
+	cmInformer coreInformer.ConfigMapInformer, secretInformer coreInformer.SecretInformer,
+	appInformer appInformer.AppInformer, opts ...Option) *appController {
+	ctl := &appController{
+		internalClient:    internalClient,
+		appClient:         appClient,
+		deploymentLister:  deployInformer.Lister(),
+		statefulSetLister: stsInformer.Lister(),
+		jobLister:         jobInformer.Lister(),
+		cronJobLister:     cronJobInformer.Lister(),
+		serviceLister:     svcInformer.Lister(),
+		ingressLister:     ingInformer.Lister(),
+		hpaLister:         hpaInformer.Lister(),
+		pdbLister:         pdbInformer.Lister(),
+		configMapLister:   cmInformer.Lister(),
+		secretLister:      secretInformer.Lister(),
+		appLister:         appInformer.Lister(),
+		queueName:         DefaultQueueName,
+		fieldManager:      DefaultFieldManager,
+		forceConflicts:    true,
+		drainTimeout:      DefaultDrainTimeout,
+	}
+	for _, opt := range opts {
+		opt(ctl)
+	}
+	ctl.queue = workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), ctl.queueName)
+	if ctl.recorder == nil {
+		ctl.recorder = newEventRecorder(internalClient)
+	}
+
+	// event handler
+	deployInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: ctl.updateChildEvent,
+		DeleteFunc: ctl.deleteDeploymentEvent,
+	})
+	stsInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: ctl.updateChildEvent,
+		DeleteFunc: ctl.deleteStatefulSetEvent,
+	})
+	jobInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: ctl.updateChildEvent,
+		DeleteFunc: ctl.deleteJobEvent,
+	})
+	cronJobInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: ctl.updateChildEvent,
+		DeleteFunc: ctl.deleteCronJobEvent,
+	})
+	svcInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: ctl.updateChildEvent,
+		DeleteFunc: ctl.deleteSvcEvent,
+	})
+	ingInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: ctl.updateChildEvent,
+		DeleteFunc: ctl.deleteIngressEvent,
 	})
-	return &ctl
+	hpaInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: ctl.updateChildEvent,
+		DeleteFunc: ctl.deleteHorizontalPodAutoscalerEvent,
+	})
+	pdbInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: ctl.updateChildEvent,
+		DeleteFunc: ctl.deletePodDisruptionBudgetEvent,
+	})
+	// referenced configuration, a change rolls out the deployment of every app referencing it
+	cmInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    ctl.configMapEvent,
+		UpdateFunc: ctl.updateConfigEvent(ctl.configMapEvent),
+		DeleteFunc: ctl.configMapEvent,
+	})
+	secretInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    ctl.secretEvent,
+		UpdateFunc: ctl.updateConfigEvent(ctl.secretEvent),
+		DeleteFunc: ctl.secretEvent,
+	})
+	appInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    ctl.addAppEvent,
+		UpdateFunc: ctl.updateAppEvent,
+		DeleteFunc: ctl.deleteAppEvent,
+	})
+	return ctl
 }
 
-func (c *appController) Run(workerNum uint32, stopCh <-chan struct{}) {
+// Run starts workerNum workers and blocks until ctx is cancelled. On cancellation the queue stops taking new
+// keys and the workers get up to the drain timeout to finish the keys already queued or in flight.
+func (c *appController) Run(ctx context.Context, workerNum uint32) {
+	defer runtime.HandleCrash()
+
+	var wg sync.WaitGroup
 	for i := 0; uint32(i) < workerNum; i++ {
-		wait.Until(c.worker, time.Second*30, stopCh)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.worker()
+		}()
+	}
+	fmt.Printf("started %d workers\n", workerNum)
+
+	<-ctx.Done()
+	fmt.Println("shutting down workers")
+	drained := make(chan struct{})
+	go func() {
+		c.queue.ShutDownWithDrain()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		wg.Wait()
+		fmt.Println("workers drained")
+	case <-time.After(c.drainTimeout):
+		// do not wait any longer for workers stuck in a sync
+		c.queue.ShutDown()
+		fmt.Printf("workers not drained after %s, forced shut down\n", c.drainTimeout)
 	}
-	<-stopCh
 }
 
+// worker processes keys until the queue is shut down
 func (c *appController) worker() {
-	for {
-		if !c.processNextEventKey() {
-			fmt.Printf("handle event fail\n")
-		}
+	for c.processNextEventKey() {
 	}
 }
 
@@ -108,10 +234,15 @@ func (c *appController) processNextEventKey() bool {
 		return false
 	}
 	defer c.queue.Done(key)
+	start := time.Now()
 	err := c.syncHandler(key.(string))
+	metrics.ReconcileTime.WithLabelValues(controllerName).Observe(time.Since(start).Seconds())
 	if err != nil {
-		c.handleErr(key.(string), err)
+		metrics.ReconcileTotal.WithLabelValues(controllerName, c.handleErr(key.(string), err)).Inc()
+		return true
 	}
+	metrics.ReconcileTotal.WithLabelValues(controllerName, metrics.ResultSuccess).Inc()
+	c.queue.Forget(key)
 	return true
 }
 
@@ -123,113 +254,226 @@ func (c *appController) syncHandler(key string) error {
 
 	app, err := c.appLister.Apps(namespace).Get(name)
 	if errors.IsNotFound(err) {
-		// delete deploy, service, ingress
-		deploys, err := c.internalClient.AppsV1().Deployments(namespace).List(context.TODO(), metav1.ListOptions{
-			LabelSelector: fmt.Sprintf("%s=%s", controllerBy, name),
-		})
-		if err != nil {
-			return err
-		}
+		// children are torn down by the finalizer before the app goes away
+		return nil
+	}
+	if err != nil {
+		return err
+	}
 
-		for _, item := range deploys.Items {
-			_ = c.internalClient.AppsV1().Deployments(namespace).Delete(context.TODO(), item.Name, metav1.DeleteOptions{})
-		}
-		services, err := c.internalClient.CoreV1().Services(namespace).List(context.TODO(), metav1.ListOptions{
-			LabelSelector: fmt.Sprintf("%s=%s", controllerBy, name),
-		})
-		if err != nil {
-			return err
+	if app.DeletionTimestamp != nil {
+		if err := c.finalizeApp(app); err != nil {
+			c.recorder.Eventf(app, core.EventTypeWarning, EventReasonSyncFailed, "Failed to tear down children: %v", err)
+			return utilerrors.NewAggregate([]error{err, c.updateAppStatus(app, app.Status.Rollout, err)})
 		}
-		for _, item := range services.Items {
-			_ = c.internalClient.CoreV1().Services(namespace).Delete(context.TODO(), item.Name, metav1.DeleteOptions{})
+		return nil
+	}
+	app, err = c.ensureFinalizer(app)
+	if err != nil {
+		return err
+	}
+	// apps admitted without the defaulting webhook get the same defaults, they are not written back
+	app = app.DeepCopy()
+	appcontrollerv1.SetDefaults_App(app)
+	if errs := validation.ValidateApp(app); len(errs) > 0 {
+		// retrying cannot fix an invalid spec, wait for the app to be updated
+		c.recorder.Eventf(app, core.EventTypeWarning, EventReasonInvalidSpec, "Invalid spec: %v", errs.ToAggregate())
+		return c.updateAppStatus(app, app.Status.Rollout, invalidSpecError{errs.ToAggregate()})
+	}
+	if isPaused(app) {
+		// the children are left alone, e.g. while edited by hand, only their drift is reported.
+		// rollout commands wait for the resume.
+		if !wasPaused(app) {
+			fmt.Printf("pause app %s\n", key)
+			c.recorder.Event(app, core.EventTypeNormal, EventReasonPaused, "Paused reconciliation")
 		}
+		return c.updateAppStatus(app, app.Status.Rollout, nil)
+	}
+	if wasPaused(app) {
+		fmt.Printf("resume app %s\n", key)
+		c.recorder.Event(app, core.EventTypeNormal, EventReasonResumed, "Resumed reconciliation")
+	}
+	// promote and abort are consumed before they are acted on, a failed sync must not act on them twice
+	if err := c.clearRolloutCommands(app); err != nil {
+		return err
+	}
 
-		ingresses, err := c.internalClient.NetworkingV1().Ingresses(namespace).List(context.TODO(), metav1.ListOptions{
-			LabelSelector: fmt.Sprintf("%s=%s", controllerBy, name),
-		})
-		if err != nil {
-			return err
-		}
-		for _, item := range ingresses.Items {
-			_ = c.internalClient.NetworkingV1().Ingresses(namespace).Delete(context.TODO(), item.Name, metav1.DeleteOptions{})
-		}
+	rollout, syncErr := c.syncChildren(app)
+	if syncErr != nil {
+		c.recorder.Eventf(app, core.EventTypeWarning, EventReasonSyncFailed, "Failed to sync: %v", syncErr)
 	}
+	statusErr := c.updateAppStatus(app, rollout, syncErr)
+	if syncErr != nil || statusErr != nil {
+		return utilerrors.NewAggregate([]error{syncErr, statusErr})
+	}
+	return nil
+}
+
+// syncChildren drives the workload, service, ingress, autoscaler and disruption budget of app towards its spec and prunes stale children.
+// It returns the new state of the rollout, which the children follow.
+func (c *appController) syncChildren(app *appcontrollerv1.App) (*appcontrollerv1.RolloutStatus, error) {
+	rollout, err := c.syncRollout(app)
 	if err != nil {
-		return err
+		return app.Status.Rollout, err
+	}
+	app = app.DeepCopy()
+	app.Status.Rollout = rollout
+
+	if err := c.syncWorkload(app); err != nil {
+		return rollout, err
+	}
+	if err := c.syncService(app); err != nil {
+		return rollout, err
+	}
+	if err := c.syncIngress(app); err != nil {
+		return rollout, err
+	}
+	if err := c.syncAutoscaler(app); err != nil {
+		return rollout, err
+	}
+	if err := c.syncDisruptionBudget(app); err != nil {
+		return rollout, err
+	}
+	if err := c.syncRolloutChildren(app); err != nil {
+		return rollout, err
+	}
+	if err := c.syncComponents(app); err != nil {
+		return rollout, err
+	}
+	// stale children are only removed once their replacements are in place
+	return rollout, c.pruneChildren(app)
+}
+
+// specChanged reports whether app carries a spec the controller has not successfully applied yet.
+// A resumed app is applied in full, its children may have been changed by hand while paused.
+func specChanged(app *appcontrollerv1.App) bool {
+	return app.Generation != app.Status.ObservedGeneration || wasPaused(app)
+}
+
+// syncWorkload syncs the deployment, statefulset, job or cronjob of app, depending on its workload kind
+func (c *appController) syncWorkload(app *appcontrollerv1.App) error {
+	switch workloadKind(app) {
+	case appcontrollerv1.StatefulSetWorkloadKind:
+		return c.syncStatefulSet(app)
+	case appcontrollerv1.JobWorkloadKind:
+		return c.syncJob(app)
+	case appcontrollerv1.CronJobWorkloadKind:
+		return c.syncCronJob(app)
 	}
+	return c.syncDeployment(app)
+}
 
-	deploy, err := c.deploymentLister.Deployments(namespace).Get(app.Spec.Deployment.Name)
+func (c *appController) syncDeployment(app *appcontrollerv1.App) error {
+	deploy, err := c.deploymentLister.Deployments(app.Namespace).Get(app.Spec.Deployment.Name)
 	if err != nil && !errors.IsNotFound(err) {
 		return err
 	}
 	if errors.IsNotFound(err) {
 		// create deployment
-		newDeploy := c.constructDeployment(app, 0)
-		_, err := c.internalClient.AppsV1().Deployments(namespace).Create(context.TODO(), newDeploy, metav1.CreateOptions{})
-		if err != nil {
+		if err := c.applyDeployment(c.constructDeployment(app, 0)); err != nil {
 			return err
 		}
 		fmt.Println("create deployment success")
-	} else if app.Spec.Deployment.Replicas != *deploy.Spec.Replicas {
-		// update replicas
-		updateDeploy := c.constructDeployment(app, app.Spec.Deployment.Replicas)
-		_, err := c.internalClient.AppsV1().Deployments(namespace).Update(context.TODO(), updateDeploy, metav1.UpdateOptions{})
-		if err != nil {
-			return err
-		}
+		c.recorder.Eventf(app, core.EventTypeNormal, EventReasonCreated, "Created deployment %s", app.Spec.Deployment.Name)
+		return nil
 	}
-	svc, err := c.serviceLister.Services(namespace).Get(app.Spec.Service.Name)
+	// repair any drift on the fields owned by the app
+	_, err = c.repairDeployment(app, deploy)
+	return err
+}
+
+// childSync describes a service, ingress, autoscaler or disruption budget of an app for syncChild
+type childSync struct {
+	kind    string
+	name    string
+	enabled bool
+	get     func() (metav1.Object, error)
+	apply   func() error
+	delete  func() error
+	// stale reports whether the live child is out of date without a change of the spec
+	stale func(live metav1.Object) bool
+}
+
+// syncChild applies an enabled child when it is missing or out of date and deletes a disabled one
+func (c *appController) syncChild(app *appcontrollerv1.App, child childSync) error {
+	live, err := child.get()
 	if err != nil && !errors.IsNotFound(err) {
 		return err
 	}
-	if errors.IsNotFound(err) && app.Spec.Service.Enabled {
-		// create service
-		newSvc := c.constructService(app)
-		_, err := c.internalClient.CoreV1().Services(namespace).Create(context.TODO(), newSvc, metav1.CreateOptions{})
-		if err != nil {
-			return err
+	found := err == nil
+	if !child.enabled {
+		if !found || !metav1.IsControlledBy(live, app) {
+			return nil
 		}
-		fmt.Println("create service success")
-	} else if !errors.IsNotFound(err) && !app.Spec.Service.Enabled {
-		err := c.internalClient.CoreV1().Services(namespace).Delete(context.TODO(), svc.Name, metav1.DeleteOptions{})
-		if err != nil {
+		if err := child.delete(); err != nil && !errors.IsNotFound(err) {
 			return err
 		}
-		fmt.Println("delete service success")
+		fmt.Printf("delete %s success\n", child.kind)
+		c.recorder.Eventf(app, core.EventTypeNormal, EventReasonDeleted, "Deleted %s %s", child.kind, child.name)
+		return nil
 	}
-
-	ingress, err := c.ingressLister.Ingresses(namespace).Get(app.Spec.Ingress.Name)
-	if err != nil && !errors.IsNotFound(err) {
+	if found && !metav1.IsControlledBy(live, app) {
+		return fmt.Errorf("%s %s/%s already exists and is not managed by app %s", child.kind, live.GetNamespace(), live.GetName(), app.Name)
+	}
+	if found && !specChanged(app) && (child.stale == nil || !child.stale(live)) {
+		return nil
+	}
+	if err := child.apply(); err != nil {
 		return err
 	}
-	if errors.IsNotFound(err) && app.Spec.Service.Enabled && app.Spec.Ingress.Enabled {
-		// create ingress
-		newIng := c.constructIngress(app)
-		_, err := c.internalClient.NetworkingV1().Ingresses(namespace).Create(context.TODO(), newIng, metav1.CreateOptions{})
-		if err != nil {
-			return err
-		}
-		fmt.Println("create ingress success")
-	} else if !errors.IsNotFound(err) {
-		if !app.Spec.Service.Enabled || !app.Spec.Ingress.Enabled {
-			// delete ingress
-			err := c.internalClient.NetworkingV1().Ingresses(namespace).Delete(context.TODO(), ingress.Name, metav1.DeleteOptions{})
-			if err != nil {
-				return err
-			}
-			fmt.Println("delete service success")
-		}
+	fmt.Printf("apply %s success\n", child.kind)
+	if found {
+		c.recorder.Eventf(app, core.EventTypeNormal, EventReasonUpdated, "Updated %s %s", child.kind, child.name)
+	} else {
+		c.recorder.Eventf(app, core.EventTypeNormal, EventReasonCreated, "Created %s %s", child.kind, child.name)
 	}
 	return nil
 }
 
-func (c *appController) handleErr(key string, err error) {
-	if c.queue.NumRequeues(key) >= 10 {
+func (c *appController) syncService(app *appcontrollerv1.App) error {
+	name := app.Spec.Service.Name
+	desired := c.constructService(app)
+	return c.syncChild(app, childSync{
+		kind:    "service",
+		name:    name,
+		enabled: app.Spec.Service.Enabled,
+		get:     func() (metav1.Object, error) { return c.serviceLister.Services(app.Namespace).Get(name) },
+		apply:   func() error { return c.applyService(desired) },
+		delete: func() error {
+			return c.internalClient.CoreV1().Services(app.Namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+		},
+		// the selector also moves during a blue/green promotion
+		stale: func(live metav1.Object) bool {
+			return !equality.Semantic.DeepEqual(live.(*core.Service).Spec.Selector, desired.Spec.Selector)
+		},
+	})
+}
+
+func (c *appController) syncIngress(app *appcontrollerv1.App) error {
+	name := app.Spec.Ingress.Name
+	return c.syncChild(app, childSync{
+		kind: "ingress",
+		name: name,
+		// ingress routes to the service, so it is only managed together with it
+		enabled: app.Spec.Service.Enabled && app.Spec.Ingress.Enabled,
+		get:     func() (metav1.Object, error) { return c.ingressLister.Ingresses(app.Namespace).Get(name) },
+		apply:   func() error { return c.applyIngress(c.constructIngress(app)) },
+		delete: func() error {
+			return c.internalClient.NetworkingV1().Ingresses(app.Namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+		},
+	})
+}
+
+// handleErr requeues key with backoff until it has failed too often, and returns the reconcile result
+func (c *appController) handleErr(key string, err error) string {
+	if c.queue.NumRequeues(key) < 10 {
+		fmt.Printf("sync app %s failed, retry: %v\n", key, err)
 		c.queue.AddRateLimited(key)
-		return
+		return metrics.ResultError
 	}
 	c.queue.Forget(key)
 	runtime.HandleError(err)
+	return metrics.ResultDropped
 }
 
 func (c *appController) enqueue(key string) {
@@ -237,36 +481,84 @@ func (c *appController) enqueue(key string) {
 }
 
 func (c *appController) deleteDeploymentEvent(obj interface{}) {
-	key, _ := cache.MetaNamespaceKeyFunc(obj)
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
 	fmt.Printf("delete deploy event... %s\n", key)
-	deploy := obj.(*deployapps.Deployment)
-	ownerReference := metav1.GetControllerOf(deploy)
-	if ownerReference.Kind != "App" {
-		return
-	}
-	c.enqueue(deploy.Namespace + "/" + deploy.GetOwnerReferences()[0].Name)
+	c.enqueueController(obj)
+}
+
+func (c *appController) deleteStatefulSetEvent(obj interface{}) {
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	fmt.Printf("delete statefulset event... %s\n", key)
+	c.enqueueController(obj)
+}
+
+func (c *appController) deleteJobEvent(obj interface{}) {
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	fmt.Printf("delete job event... %s\n", key)
+	c.enqueueController(obj)
+}
+
+func (c *appController) deleteCronJobEvent(obj interface{}) {
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	fmt.Printf("delete cronjob event... %s\n", key)
+	c.enqueueController(obj)
 }
 
 func (c *appController) deleteSvcEvent(obj interface{}) {
-	key, _ := cache.MetaNamespaceKeyFunc(obj)
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
 	fmt.Printf("delete service event... %s\n", key)
-	svc := obj.(*core.Service)
-	ownerReference := metav1.GetControllerOf(svc)
-	if ownerReference.Kind != "App" {
-		return
-	}
-	c.enqueue(svc.Namespace + "/" + svc.GetOwnerReferences()[0].Name)
+	c.enqueueController(obj)
 }
 
 func (c *appController) deleteIngressEvent(obj interface{}) {
-	key, _ := cache.MetaNamespaceKeyFunc(obj)
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
 	fmt.Printf("delete ingress event... %s\n", key)
-	ingress := obj.(*net.Ingress)
-	ownerReference := metav1.GetControllerOf(ingress)
-	if ownerReference.Kind != "App" {
+	c.enqueueController(obj)
+}
+
+func (c *appController) deleteHorizontalPodAutoscalerEvent(obj interface{}) {
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	fmt.Printf("delete horizontalpodautoscaler event... %s\n", key)
+	c.enqueueController(obj)
+}
+
+func (c *appController) deletePodDisruptionBudgetEvent(obj interface{}) {
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	fmt.Printf("delete poddisruptionbudget event... %s\n", key)
+	c.enqueueController(obj)
+}
+
+// enqueueController enqueues the app controlling obj, if any. obj may be a deletion tombstone.
+func (c *appController) enqueueController(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	object, err := meta.Accessor(obj)
+	if err != nil {
 		return
 	}
-	c.enqueue(ingress.Namespace + "/" + ingress.GetOwnerReferences()[0].Name)
+	ownerReference := metav1.GetControllerOf(object)
+	if ownerReference == nil || ownerReference.Kind != "App" {
+		return
+	}
+	c.enqueue(object.GetNamespace() + "/" + ownerReference.Name)
+}
+
+// updateChildEvent requeues the owning app when a managed child changes, so that status follows its readiness
+func (c *appController) updateChildEvent(oldObj interface{}, newObj interface{}) {
+	oldMeta, err := meta.Accessor(oldObj)
+	if err != nil {
+		return
+	}
+	newMeta, err := meta.Accessor(newObj)
+	if err != nil {
+		return
+	}
+	// periodic resync sends the same object again
+	if oldMeta.GetResourceVersion() == newMeta.GetResourceVersion() {
+		return
+	}
+	c.enqueueController(newObj)
 }
 
 func (c *appController) addAppEvent(obj interface{}) {
@@ -299,12 +591,16 @@ func (c *appController) deleteAppEvent(obj interface{}) {
 func (c *appController) constructDeployment(app *appcontrollerv1.App, replicas int32) *deployapps.Deployment {
 	labels := map[string]string{
 		"app":        app.Name,
-		"controller": app.Name,
+		"controller": selectorName(app),
 	}
 	if replicas <= 0 {
 		replicas = app.Spec.Deployment.Replicas
 	}
-	return &deployapps.Deployment{
+	deploy := &deployapps.Deployment{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: deployapps.SchemeGroupVersion.String(),
+			Kind:       "Deployment",
+		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      app.Spec.Deployment.Name,
 			Namespace: app.Namespace,
@@ -312,7 +608,7 @@ func (c *appController) constructDeployment(app *appcontrollerv1.App, replicas i
 				controllerBy: app.Name,
 			},
 			OwnerReferences: []metav1.OwnerReference{
-				*metav1.NewControllerRef(app, metav1.SchemeGroupVersion.WithKind("App")),
+				*metav1.NewControllerRef(app, appcontrollerv1.SchemeGroupVersion.WithKind("App")),
 			},
 		},
 		Spec: deployapps.DeploymentSpec{
@@ -320,29 +616,69 @@ func (c *appController) constructDeployment(app *appcontrollerv1.App, replicas i
 			Selector: &metav1.LabelSelector{
 				MatchLabels: labels,
 			},
-			Template: core.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: labels,
-				},
-				Spec: core.PodSpec{
-					Containers: []core.Container{
-						{
-							Name:  app.Spec.Deployment.Name,
-							Image: app.Spec.Deployment.Image,
-						},
-					},
+			Template: c.constructPodTemplate(app, labels),
+		},
+	}
+	if autoscalingEnabled(app) {
+		// leave replicas to the autoscaler, applying them would scale the deployment back on every sync.
+		// repairDeployment hands the live count over first, see handoverReplicas.
+		deploy.Spec.Replicas = nil
+	}
+	return deploy
+}
+
+// constructPodTemplate builds the pod template shared by the workloads of app
+func (c *appController) constructPodTemplate(app *appcontrollerv1.App, labels map[string]string) core.PodTemplateSpec {
+	template := core.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: labels,
+		},
+		Spec: core.PodSpec{
+			Containers: []core.Container{
+				{
+					Name:            app.Spec.Deployment.Name,
+					Image:           deploymentImage(app),
+					ImagePullPolicy: app.Spec.Deployment.ImagePullPolicy,
+					Command:         app.Spec.Deployment.Command,
+					Args:            app.Spec.Deployment.Args,
+					Ports:           containerPorts(app),
+					Env:             app.Spec.Deployment.Env,
+					Resources:       app.Spec.Deployment.Resources,
+					LivenessProbe:   app.Spec.Deployment.LivenessProbe,
+					ReadinessProbe:  app.Spec.Deployment.ReadinessProbe,
+					StartupProbe:    app.Spec.Deployment.StartupProbe,
+					VolumeMounts:    app.Spec.Deployment.VolumeMounts,
 				},
 			},
+			Volumes: app.Spec.Deployment.Volumes,
 		},
 	}
+	if hash := c.configHash(app); hash != "" {
+		template.Annotations = map[string]string{configHashAnnotation: hash}
+	}
+	return template
 }
 
 func (c *appController) constructService(app *appcontrollerv1.App) *core.Service {
 	labels := map[string]string{
 		"app":        app.Name,
-		"controller": app.Name,
+		"controller": selectorName(app) + serviceSelectorSuffix(app),
+	}
+	var ports []core.ServicePort
+	for _, port := range servicePorts(app) {
+		ports = append(ports, core.ServicePort{
+			Name:       port.Name,
+			Protocol:   port.Protocol,
+			Port:       port.Port,
+			TargetPort: intstr.FromInt(int(port.TargetPort)),
+			NodePort:   port.NodePort,
+		})
 	}
-	return &core.Service{
+	svc := &core.Service{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: core.SchemeGroupVersion.String(),
+			Kind:       "Service",
+		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      app.Spec.Service.Name,
 			Namespace: app.Namespace,
@@ -350,57 +686,161 @@ func (c *appController) constructService(app *appcontrollerv1.App) *core.Service
 				controllerBy: app.Name,
 			},
 			OwnerReferences: []metav1.OwnerReference{
-				*metav1.NewControllerRef(app, metav1.SchemeGroupVersion.WithKind("App")),
+				*metav1.NewControllerRef(app, appcontrollerv1.SchemeGroupVersion.WithKind("App")),
 			},
 		},
 		Spec: core.ServiceSpec{
 			Selector: labels,
-			Ports: []core.ServicePort{
-				{
-					Protocol:   core.ProtocolTCP,
-					Port:       80,
-					TargetPort: intstr.IntOrString{IntVal: 80},
-				},
-			},
+			Type:     app.Spec.Service.Type,
+			Ports:    ports,
 		},
 	}
+	if app.Spec.Service.Headless {
+		svc.Spec.ClusterIP = core.ClusterIPNone
+	}
+	if app.Spec.Service.SessionAffinity != "" {
+		svc.Spec.SessionAffinity = app.Spec.Service.SessionAffinity
+	}
+	if app.Spec.Service.SessionAffinityTimeoutSeconds != nil {
+		timeout := *app.Spec.Service.SessionAffinityTimeoutSeconds
+		svc.Spec.SessionAffinityConfig = &core.SessionAffinityConfig{
+			ClientIP: &core.ClientIPConfig{TimeoutSeconds: &timeout},
+		}
+	}
+	return svc
+}
+
+// servicePorts returns the ports declared on the app service with defaults applied
+func servicePorts(app *appcontrollerv1.App) []appcontrollerv1.ServicePort {
+	if len(app.Spec.Service.Ports) == 0 {
+		return []appcontrollerv1.ServicePort{{Protocol: core.ProtocolTCP, Port: 80, TargetPort: 80}}
+	}
+	ports := make([]appcontrollerv1.ServicePort, 0, len(app.Spec.Service.Ports))
+	for _, port := range app.Spec.Service.Ports {
+		if port.Protocol == "" {
+			port.Protocol = core.ProtocolTCP
+		}
+		if port.TargetPort == 0 {
+			port.TargetPort = port.Port
+		}
+		ports = append(ports, port)
+	}
+	return ports
+}
+
+// containerPorts exposes the target ports of the service on the app container
+func containerPorts(app *appcontrollerv1.App) []core.ContainerPort {
+	var ports []core.ContainerPort
+	seen := map[string]bool{}
+	for _, port := range servicePorts(app) {
+		key := fmt.Sprintf("%d/%s", port.TargetPort, port.Protocol)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		ports = append(ports, core.ContainerPort{
+			Name:          port.Name,
+			ContainerPort: port.TargetPort,
+			Protocol:      port.Protocol,
+		})
+	}
+	return ports
+}
+
+// ingressBackendPort returns the first TCP port of the app service, which the ingress routes to
+func ingressBackendPort(app *appcontrollerv1.App) net.ServiceBackendPort {
+	for _, port := range servicePorts(app) {
+		if port.Protocol != core.ProtocolTCP {
+			continue
+		}
+		if port.Name != "" {
+			return net.ServiceBackendPort{Name: port.Name}
+		}
+		return net.ServiceBackendPort{Number: port.Port}
+	}
+	return net.ServiceBackendPort{Number: 80}
 }
 
 func (c *appController) constructIngress(app *appcontrollerv1.App) *net.Ingress {
 	ing := net.Ingress{}
+	ing.APIVersion = net.SchemeGroupVersion.String()
+	ing.Kind = "Ingress"
 	ing.Namespace = app.Namespace
 	ing.Name = app.Spec.Ingress.Name
-	ing.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(app, metav1.SchemeGroupVersion.WithKind("App"))}
-	pathType := net.PathTypePrefix
-	ingressClassName := "nginx"
+	ing.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(app, appcontrollerv1.SchemeGroupVersion.WithKind("App"))}
 	ing.Labels = map[string]string{
 		controllerBy: app.Name,
 	}
-	ing.Spec = net.IngressSpec{
-		IngressClassName: &ingressClassName,
-		Rules: []net.IngressRule{
-			{
-				Host: "testing.com",
-				IngressRuleValue: net.IngressRuleValue{
-					HTTP: &net.HTTPIngressRuleValue{
-						Paths: []net.HTTPIngressPath{
-							{
-								Path:     "/",
-								PathType: &pathType,
-								Backend: net.IngressBackend{
-									Service: &net.IngressServiceBackend{
-										Name: app.Spec.Service.Name,
-										Port: net.ServiceBackendPort{
-											Number: 80,
-										},
-									},
-								},
-							},
-						},
-					},
+	ing.Annotations = app.Spec.Ingress.Annotations
+
+	ingressClassName := "nginx"
+	if app.Spec.Ingress.ClassName != nil {
+		ingressClassName = *app.Spec.Ingress.ClassName
+	}
+	if ingressClassName != "" {
+		ing.Spec.IngressClassName = &ingressClassName
+	}
+
+	rules := app.Spec.Ingress.Rules
+	if len(rules) == 0 {
+		rules = []appcontrollerv1.IngressRule{{Host: "testing.com"}}
+	}
+	for _, rule := range rules {
+		paths := rule.Paths
+		if len(paths) == 0 {
+			paths = []appcontrollerv1.IngressPath{{}}
+		}
+		httpPaths := make([]net.HTTPIngressPath, 0, len(paths))
+		for _, path := range paths {
+			httpPaths = append(httpPaths, ingressPath(app, path))
+		}
+		ing.Spec.Rules = append(ing.Spec.Rules, net.IngressRule{
+			Host: rule.Host,
+			IngressRuleValue: net.IngressRuleValue{
+				HTTP: &net.HTTPIngressRuleValue{
+					Paths: httpPaths,
 				},
 			},
-		},
+		})
+	}
+
+	for _, tls := range app.Spec.Ingress.TLS {
+		ing.Spec.TLS = append(ing.Spec.TLS, net.IngressTLS{
+			Hosts:      tls.Hosts,
+			SecretName: tls.SecretName,
+		})
 	}
 	return &ing
 }
+
+// ingressPath builds the http path of an ingress rule, routing to the app service unless another backend is set
+func ingressPath(app *appcontrollerv1.App, path appcontrollerv1.IngressPath) net.HTTPIngressPath {
+	pathType := net.PathTypePrefix
+	if path.PathType != "" {
+		pathType = path.PathType
+	}
+	httpPath := net.HTTPIngressPath{
+		Path:     path.Path,
+		PathType: &pathType,
+		Backend: net.IngressBackend{
+			Service: &net.IngressServiceBackend{
+				Name: app.Spec.Service.Name,
+				Port: ingressBackendPort(app),
+			},
+		},
+	}
+	if httpPath.Path == "" {
+		httpPath.Path = "/"
+	}
+	if path.ServiceName != "" {
+		httpPath.Backend.Service.Name = path.ServiceName
+	}
+	if path.ServicePort != nil {
+		if path.ServicePort.Type == intstr.String {
+			httpPath.Backend.Service.Port = net.ServiceBackendPort{Name: path.ServicePort.StrVal}
+		} else {
+			httpPath.Backend.Service.Port = net.ServiceBackendPort{Number: path.ServicePort.IntVal}
+		}
+	}
+	return httpPath
+}
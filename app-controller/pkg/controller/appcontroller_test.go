@@ -0,0 +1,1832 @@
+/*******************************************************************************
+ * @File: appcontroller_test.go
+ * @Description: syncHandler tests on fake clientsets
+ * @Author: jiangxunyu
+ * @Version: 1.0.0
+ * @Date: 2026/10/18 21:00
+*******************************************************************************/
+
+package controller
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	appcontrollerv1 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v1"
+	appcontrollerv2 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v2"
+	"github.com/istudies/k8s-operator/app-controller/pkg/generated/clientset/versioned/fake"
+	"github.com/istudies/k8s-operator/app-controller/pkg/generated/informers/externalversions"
+	deployapps "k8s.io/api/apps/v1"
+	autoscaling "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	net "k8s.io/api/networking/v1"
+	policy "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	core_testing "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+var noResyncPeriodFunc = func() time.Duration { return 0 }
+
+// fixture seeds fake clientsets and informer caches, runs syncHandler and checks the recorded actions
+type fixture struct {
+	t *testing.T
+
+	client     *fake.Clientset
+	kubeclient *k8sfake.Clientset
+	recorder   *record.FakeRecorder
+
+	// objects to put in the informer caches
+	appLister         []*appcontrollerv1.App
+	deploymentLister  []*deployapps.Deployment
+	statefulSetLister []*deployapps.StatefulSet
+	jobLister         []*batchv1.Job
+	cronJobLister     []*batchv1.CronJob
+	serviceLister     []*core.Service
+	ingressLister     []*net.Ingress
+	hpaLister         []*autoscaling.HorizontalPodAutoscaler
+	pdbLister         []*policy.PodDisruptionBudget
+	configMapLister   []*core.ConfigMap
+	secretLister      []*core.Secret
+
+	// actions expected to happen on the clients
+	actions     []core_testing.Action
+	kubeactions []core_testing.Action
+
+	// objects preloaded into the fake clientsets
+	objects     []runtime.Object
+	kubeobjects []runtime.Object
+}
+
+func newFixture(t *testing.T) *fixture {
+	return &fixture{t: t}
+}
+
+func (f *fixture) newController() *appController {
+	f.client = fake.NewSimpleClientset(f.objects...)
+	f.kubeclient = k8sfake.NewSimpleClientset(f.kubeobjects...)
+	// the object tracker of the fake clientset does not implement server-side apply
+	f.kubeclient.PrependReactor("patch", "*", func(action core_testing.Action) (bool, runtime.Object, error) {
+		if action.(core_testing.PatchAction).GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+		return true, nil, nil
+	})
+	f.recorder = record.NewFakeRecorder(100)
+
+	i := externalversions.NewSharedInformerFactory(f.client, noResyncPeriodFunc())
+	k8sI := informers.NewSharedInformerFactory(f.kubeclient, noResyncPeriodFunc())
+
+	c := NewAppController(f.kubeclient, f.client,
+		k8sI.Apps().V1().Deployments(), k8sI.Apps().V1().StatefulSets(), k8sI.Batch().V1().Jobs(), k8sI.Batch().V1().CronJobs(),
+		k8sI.Core().V1().Services(), k8sI.Networking().V1().Ingresses(),
+		k8sI.Autoscaling().V2().HorizontalPodAutoscalers(), k8sI.Policy().V1().PodDisruptionBudgets(), k8sI.Core().V1().ConfigMaps(), k8sI.Core().V1().Secrets(),
+		i.Appcontroller().V1().Apps(), WithEventRecorder(f.recorder))
+
+	for _, app := range f.appLister {
+		f.add(i.Appcontroller().V1().Apps().Informer().GetIndexer(), app)
+	}
+	for _, deploy := range f.deploymentLister {
+		f.add(k8sI.Apps().V1().Deployments().Informer().GetIndexer(), deploy)
+	}
+	for _, sts := range f.statefulSetLister {
+		f.add(k8sI.Apps().V1().StatefulSets().Informer().GetIndexer(), sts)
+	}
+	for _, job := range f.jobLister {
+		f.add(k8sI.Batch().V1().Jobs().Informer().GetIndexer(), job)
+	}
+	for _, cj := range f.cronJobLister {
+		f.add(k8sI.Batch().V1().CronJobs().Informer().GetIndexer(), cj)
+	}
+	for _, svc := range f.serviceLister {
+		f.add(k8sI.Core().V1().Services().Informer().GetIndexer(), svc)
+	}
+	for _, ingress := range f.ingressLister {
+		f.add(k8sI.Networking().V1().Ingresses().Informer().GetIndexer(), ingress)
+	}
+	for _, hpa := range f.hpaLister {
+		f.add(k8sI.Autoscaling().V2().HorizontalPodAutoscalers().Informer().GetIndexer(), hpa)
+	}
+	for _, pdb := range f.pdbLister {
+		f.add(k8sI.Policy().V1().PodDisruptionBudgets().Informer().GetIndexer(), pdb)
+	}
+	for _, cm := range f.configMapLister {
+		f.add(k8sI.Core().V1().ConfigMaps().Informer().GetIndexer(), cm)
+	}
+	for _, secret := range f.secretLister {
+		f.add(k8sI.Core().V1().Secrets().Informer().GetIndexer(), secret)
+	}
+	return c
+}
+
+func (f *fixture) add(indexer cache.Indexer, obj interface{}) {
+	if err := indexer.Add(obj); err != nil {
+		f.t.Fatalf("seed informer cache: %v", err)
+	}
+}
+
+func (f *fixture) run(key string) {
+	f.runSync(key, false)
+}
+
+func (f *fixture) runExpectError(key string) {
+	f.runSync(key, true)
+}
+
+func (f *fixture) runSync(key string, expectError bool) {
+	c := f.newController()
+	err := c.syncHandler(key)
+	if !expectError && err != nil {
+		f.t.Errorf("error syncing app: %v", err)
+	} else if expectError && err == nil {
+		f.t.Error("expected error syncing app, got nil")
+	}
+
+	checkActions(f.t, "app", f.actions, f.client.Actions())
+	checkActions(f.t, "kube", f.kubeactions, f.kubeclient.Actions())
+}
+
+// checkActions compares verb, resource, subresource and object name of each action
+func checkActions(t *testing.T, client string, expected, actual []core_testing.Action) {
+	for i, action := range actual {
+		if len(expected) < i+1 {
+			t.Errorf("%s: %d unexpected actions: %+v", client, len(actual)-len(expected), actual[i:])
+			break
+		}
+		checkAction(t, client, expected[i], action)
+	}
+	if len(expected) > len(actual) {
+		t.Errorf("%s: %d additional expected actions: %+v", client, len(expected)-len(actual), expected[len(actual):])
+	}
+}
+
+func checkAction(t *testing.T, client string, expected, actual core_testing.Action) {
+	if !(expected.Matches(actual.GetVerb(), actual.GetResource().Resource) && actual.GetSubresource() == expected.GetSubresource()) {
+		t.Errorf("%s: expected %s %s/%s, got %s %s/%s", client,
+			expected.GetVerb(), expected.GetResource().Resource, expected.GetSubresource(),
+			actual.GetVerb(), actual.GetResource().Resource, actual.GetSubresource())
+		return
+	}
+	if name := actionName(expected); name != "" && name != actionName(actual) {
+		t.Errorf("%s: expected %s %s named %s, got %s", client,
+			expected.GetVerb(), expected.GetResource().Resource, name, actionName(actual))
+	}
+	if e, ok := expected.(core_testing.PatchAction); ok {
+		if a := actual.(core_testing.PatchAction); e.GetPatchType() != a.GetPatchType() {
+			t.Errorf("%s: expected %s patch of %s, got %s", client, e.GetPatchType(), e.GetResource().Resource, a.GetPatchType())
+		}
+	}
+}
+
+func actionName(action core_testing.Action) string {
+	switch a := action.(type) {
+	case core_testing.PatchAction:
+		return a.GetName()
+	case core_testing.DeleteAction:
+		return a.GetName()
+	case core_testing.CreateAction:
+		if obj, ok := a.GetObject().(metav1.Object); ok {
+			return obj.GetName()
+		}
+	case core_testing.UpdateAction:
+		if obj, ok := a.GetObject().(metav1.Object); ok {
+			return obj.GetName()
+		}
+	}
+	return ""
+}
+
+func (f *fixture) expectUpdateAppAction(app *appcontrollerv1.App) {
+	f.actions = append(f.actions, core_testing.NewUpdateAction(
+		appcontrollerv1.SchemeGroupVersion.WithResource("apps"), app.Namespace, app))
+}
+
+func (f *fixture) expectUpdateAppStatusAction(app *appcontrollerv1.App) {
+	f.actions = append(f.actions, core_testing.NewUpdateSubresourceAction(
+		appcontrollerv1.SchemeGroupVersion.WithResource("apps"), "status", app.Namespace, app))
+}
+
+func (f *fixture) expectPatchAppAction(app *appcontrollerv1.App) {
+	f.actions = append(f.actions, core_testing.NewPatchAction(
+		appcontrollerv1.SchemeGroupVersion.WithResource("apps"), app.Namespace, app.Name, types.MergePatchType, nil))
+}
+
+func (f *fixture) expectApplyAction(resource, namespace, name string) {
+	f.kubeactions = append(f.kubeactions, core_testing.NewPatchAction(
+		resourceGVR(resource), namespace, name, types.ApplyPatchType, nil))
+}
+
+func (f *fixture) expectMergePatchAction(resource, namespace, name string) {
+	f.kubeactions = append(f.kubeactions, core_testing.NewPatchAction(
+		resourceGVR(resource), namespace, name, types.MergePatchType, nil))
+}
+
+func (f *fixture) expectDeleteAction(resource, namespace, name string) {
+	f.kubeactions = append(f.kubeactions, core_testing.NewDeleteAction(resourceGVR(resource), namespace, name))
+}
+
+func resourceGVR(resource string) schema.GroupVersionResource {
+	switch resource {
+	case "deployments", "statefulsets":
+		return deployapps.SchemeGroupVersion.WithResource(resource)
+	case "jobs", "cronjobs":
+		return batchv1.SchemeGroupVersion.WithResource(resource)
+	case "ingresses":
+		return net.SchemeGroupVersion.WithResource(resource)
+	case "horizontalpodautoscalers":
+		return autoscaling.SchemeGroupVersion.WithResource(resource)
+	case "poddisruptionbudgets":
+		return policy.SchemeGroupVersion.WithResource(resource)
+	}
+	return core.SchemeGroupVersion.WithResource(resource)
+}
+
+func newApp(name string, replicas int32) *appcontrollerv1.App {
+	return &appcontrollerv1.App{
+		TypeMeta: metav1.TypeMeta{APIVersion: appcontrollerv1.SchemeGroupVersion.String(), Kind: "App"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       name,
+			Namespace:  metav1.NamespaceDefault,
+			UID:        types.UID(name + "-uid"),
+			Generation: 1,
+			Finalizers: []string{appFinalizer},
+		},
+		Spec: appcontrollerv1.AppSpec{
+			Deployment: appcontrollerv1.DeploymentObj{Name: name + "-deploy", Image: "nginx:latest", Replicas: replicas},
+			Service:    appcontrollerv1.ServiceObj{Enabled: true, Name: name + "-svc"},
+			Ingress:    appcontrollerv1.IngressObj{Enabled: true, Name: name + "-ingress"},
+		},
+		Status: appcontrollerv1.AppStatus{ObservedGeneration: 1},
+	}
+}
+
+// newRolloutApp returns an app running stable with a canary rollout of image in progress
+func newRolloutApp(name, stable, image string) *appcontrollerv1.App {
+	app := newApp(name, 1)
+	app.Spec.Deployment.Image = image
+	app.Spec.Strategy = appcontrollerv1.StrategyObj{
+		Type: appcontrollerv1.CanaryStrategyType,
+		Canary: &appcontrollerv1.CanaryStrategy{Steps: []appcontrollerv1.CanaryStep{
+			{Weight: 20},
+			{Weight: 50},
+		}},
+	}
+	app.Status.Rollout = &appcontrollerv1.RolloutStatus{
+		Phase:        appcontrollerv1.RolloutProgressing,
+		StableImage:  stable,
+		UpdatedImage: image,
+	}
+	return app
+}
+
+func getKey(app *appcontrollerv1.App, t *testing.T) string {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(app)
+	if err != nil {
+		t.Errorf("unexpected error getting key for app %v: %v", app.Name, err)
+		return ""
+	}
+	return key
+}
+
+// seedChildren puts the children the controller would have created for app into the caches
+func (f *fixture) seedChildren(c *appController, app *appcontrollerv1.App) {
+	// the children are applied from the defaulted app
+	app = app.DeepCopy()
+	appcontrollerv1.SetDefaults_App(app)
+	switch workloadKind(app) {
+	case appcontrollerv1.StatefulSetWorkloadKind:
+		f.statefulSetLister = append(f.statefulSetLister, c.constructStatefulSet(app))
+		f.serviceLister = append(f.serviceLister, c.constructHeadlessService(app))
+	case appcontrollerv1.JobWorkloadKind:
+		f.jobLister = append(f.jobLister, c.constructJob(app))
+	case appcontrollerv1.CronJobWorkloadKind:
+		f.cronJobLister = append(f.cronJobLister, c.constructCronJob(app))
+	default:
+		f.deploymentLister = append(f.deploymentLister, c.constructDeployment(app, 0))
+	}
+	if app.Spec.Service.Enabled {
+		f.serviceLister = append(f.serviceLister, c.constructService(app))
+	}
+	if app.Spec.Service.Enabled && app.Spec.Ingress.Enabled {
+		f.ingressLister = append(f.ingressLister, c.constructIngress(app))
+	}
+	if app.Spec.Autoscaling.Enabled {
+		f.hpaLister = append(f.hpaLister, c.constructHorizontalPodAutoscaler(app))
+	}
+	if app.Spec.DisruptionBudget.Enabled {
+		f.pdbLister = append(f.pdbLister, c.constructPodDisruptionBudget(app))
+	}
+}
+
+// seedRolloutChildren puts the available canary or preview children of the rollout of app into the caches
+func (f *fixture) seedRolloutChildren(c *appController, app *appcontrollerv1.App) {
+	app = app.DeepCopy()
+	appcontrollerv1.SetDefaults_App(app)
+	deploy := c.constructRolloutDeployment(app)
+	deploy.Status.ObservedGeneration = deploy.Generation
+	deploy.Status.UpdatedReplicas = *deploy.Spec.Replicas
+	deploy.Status.ReadyReplicas = *deploy.Spec.Replicas
+	f.deploymentLister = append(f.deploymentLister, deploy)
+	f.serviceLister = append(f.serviceLister, c.constructRolloutService(app))
+	if rolloutStrategy(app) == appcontrollerv1.CanaryStrategyType {
+		f.ingressLister = append(f.ingressLister, c.constructCanaryIngress(app))
+	}
+}
+
+// updatedStatus returns the status written by the last sync
+func (f *fixture) updatedStatus() *appcontrollerv1.AppStatus {
+	actions := f.client.Actions()
+	for i := len(actions) - 1; i >= 0; i-- {
+		if update, ok := actions[i].(core_testing.UpdateAction); ok && update.GetSubresource() == "status" {
+			return &update.GetObject().(*appcontrollerv1.App).Status
+		}
+	}
+	f.t.Fatalf("no status update")
+	return nil
+}
+
+func TestCreatesChildren(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 1)
+	app.Status = appcontrollerv1.AppStatus{}
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	f.expectApplyAction("deployments", app.Namespace, app.Spec.Deployment.Name)
+	f.expectApplyAction("services", app.Namespace, app.Spec.Service.Name)
+	f.expectApplyAction("ingresses", app.Namespace, app.Spec.Ingress.Name)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+}
+
+func TestReportsScaleSelector(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 3)
+	f.seedChildren(&appController{}, app)
+	// still scaling up
+	f.deploymentLister[0].Status.Replicas = 2
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+
+	// the scale subresource reads the observed replicas and the pod selector from status
+	status := f.updatedStatus()
+	if status.Replicas != 2 {
+		t.Errorf("expected the 2 observed replicas in status, got %d", status.Replicas)
+	}
+	if status.Selector != "app=test,controller=test" {
+		t.Errorf("unexpected status selector %q", status.Selector)
+	}
+}
+
+// newStatefulApp returns an app running as a statefulset with a data volume per pod
+func newStatefulApp(name string, replicas int32) *appcontrollerv1.App {
+	app := newApp(name, replicas)
+	app.Spec.Deployment.WorkloadKind = appcontrollerv1.StatefulSetWorkloadKind
+	app.Spec.Deployment.VolumeClaimTemplates = []core.PersistentVolumeClaim{{
+		ObjectMeta: metav1.ObjectMeta{Name: "data"},
+		Spec: core.PersistentVolumeClaimSpec{
+			AccessModes: []core.PersistentVolumeAccessMode{core.ReadWriteOnce},
+			Resources: core.ResourceRequirements{
+				Requests: core.ResourceList{core.ResourceStorage: resource.MustParse("1Gi")},
+			},
+		},
+	}}
+	app.Spec.Deployment.VolumeMounts = []core.VolumeMount{{Name: "data", MountPath: "/data"}}
+	return app
+}
+
+func TestCreatesStatefulSet(t *testing.T) {
+	f := newFixture(t)
+	app := newStatefulApp("test", 3)
+	app.Status = appcontrollerv1.AppStatus{}
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	f.expectApplyAction("services", app.Namespace, "test-deploy-headless")
+	f.expectApplyAction("statefulsets", app.Namespace, app.Spec.Deployment.Name)
+	f.expectApplyAction("services", app.Namespace, app.Spec.Service.Name)
+	f.expectApplyAction("ingresses", app.Namespace, app.Spec.Ingress.Name)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+
+	var sts deployapps.StatefulSet
+	var headless core.Service
+	for _, action := range f.kubeclient.Actions() {
+		patch, ok := action.(core_testing.PatchAction)
+		if !ok {
+			continue
+		}
+		switch {
+		case patch.GetResource().Resource == "statefulsets":
+			if err := json.Unmarshal(patch.GetPatch(), &sts); err != nil {
+				t.Fatalf("decode applied statefulset: %v", err)
+			}
+		case patch.GetName() == "test-deploy-headless":
+			if err := json.Unmarshal(patch.GetPatch(), &headless); err != nil {
+				t.Fatalf("decode applied service: %v", err)
+			}
+		}
+	}
+	if sts.Spec.ServiceName != "test-deploy-headless" {
+		t.Errorf("expected the statefulset to be governed by the headless service, got %q", sts.Spec.ServiceName)
+	}
+	if claims := sts.Spec.VolumeClaimTemplates; len(claims) != 1 || claims[0].Name != "data" {
+		t.Errorf("expected the data claim template, got %+v", claims)
+	}
+	if headless.Spec.ClusterIP != core.ClusterIPNone || !equality.Semantic.DeepEqual(headless.Spec.Selector, sts.Spec.Selector.MatchLabels) {
+		t.Errorf("expected a headless service selecting the statefulset pods, got %+v", headless.Spec)
+	}
+	if status := f.updatedStatus(); status.DeploymentName != app.Spec.Deployment.Name {
+		t.Errorf("expected the statefulset in status, got %q", status.DeploymentName)
+	}
+}
+
+func TestReplacesDeploymentWithStatefulSet(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 1)
+	f.seedChildren(&appController{}, app)
+	app = newStatefulApp("test", 1)
+	app.Generation = 2
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	f.expectApplyAction("services", app.Namespace, "test-deploy-headless")
+	f.expectApplyAction("statefulsets", app.Namespace, app.Spec.Deployment.Name)
+	f.expectApplyAction("services", app.Namespace, app.Spec.Service.Name)
+	f.expectApplyAction("ingresses", app.Namespace, app.Spec.Ingress.Name)
+	// the deployment is only pruned once the statefulset is in place
+	f.expectDeleteAction("deployments", app.Namespace, app.Spec.Deployment.Name)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+}
+
+func TestRecreatesStatefulSetWithNewClaimTemplates(t *testing.T) {
+	f := newFixture(t)
+	app := newStatefulApp("test", 1)
+	f.seedChildren(&appController{}, app)
+	app.Spec.Deployment.VolumeClaimTemplates[0].Spec.Resources.Requests[core.ResourceStorage] = resource.MustParse("10Gi")
+	app.Generation = 2
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+	// the delete is served by the object tracker
+	f.kubeobjects = append(f.kubeobjects, f.statefulSetLister[0])
+
+	f.expectApplyAction("services", app.Namespace, "test-deploy-headless")
+	f.expectDeleteAction("statefulsets", app.Namespace, app.Spec.Deployment.Name)
+	f.expectApplyAction("services", app.Namespace, app.Spec.Service.Name)
+	f.expectApplyAction("ingresses", app.Namespace, app.Spec.Ingress.Name)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+
+	// the running pods are kept for the recreated statefulset
+	for _, action := range f.kubeclient.Actions() {
+		if deletion, ok := action.(core_testing.DeleteAction); ok {
+			policy := deletion.GetDeleteOptions().PropagationPolicy
+			if policy == nil || *policy != metav1.DeletePropagationOrphan {
+				t.Errorf("expected the statefulset to be deleted with orphan propagation, got %v", policy)
+			}
+		}
+	}
+}
+
+func TestDeletesStatefulSetOfDeletedApp(t *testing.T) {
+	f := newFixture(t)
+	app := newStatefulApp("test", 1)
+	f.seedChildren(&appController{}, app)
+	now := metav1.Now()
+	app.DeletionTimestamp = &now
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	f.expectDeleteAction("ingresses", app.Namespace, app.Spec.Ingress.Name)
+	f.expectDeleteAction("services", app.Namespace, "test-deploy-headless")
+	f.expectDeleteAction("services", app.Namespace, app.Spec.Service.Name)
+	f.expectDeleteAction("statefulsets", app.Namespace, app.Spec.Deployment.Name)
+
+	f.run(getKey(app, t))
+}
+
+func TestEnqueuesAppOfDeletedStatefulSet(t *testing.T) {
+	f := newFixture(t)
+	app := newStatefulApp("test", 1)
+	f.appLister = append(f.appLister, app)
+	c := f.newController()
+
+	sts := c.constructStatefulSet(app)
+	c.deleteStatefulSetEvent(cache.DeletedFinalStateUnknown{Key: app.Namespace + "/" + sts.Name, Obj: sts})
+
+	if key, _ := c.queue.Get(); key != getKey(app, t) {
+		t.Errorf("expected %s to be enqueued, got %v", getKey(app, t), key)
+	}
+}
+
+// newCronApp returns an app running replicas pods every five minutes, without service
+func newCronApp(name string, replicas int32) *appcontrollerv1.App {
+	app := newApp(name, replicas)
+	app.Spec.Deployment.WorkloadKind = appcontrollerv1.CronJobWorkloadKind
+	app.Spec.Deployment.Batch = &appcontrollerv1.BatchObj{
+		Schedule:          "*/5 * * * *",
+		ConcurrencyPolicy: batchv1.ForbidConcurrent,
+	}
+	app.Spec.Service = appcontrollerv1.ServiceObj{}
+	app.Spec.Ingress = appcontrollerv1.IngressObj{}
+	return app
+}
+
+func TestCreatesCronJob(t *testing.T) {
+	f := newFixture(t)
+	app := newCronApp("test", 2)
+	app.Status = appcontrollerv1.AppStatus{}
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	f.expectApplyAction("cronjobs", app.Namespace, app.Spec.Deployment.Name)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+
+	var cj batchv1.CronJob
+	for _, action := range f.kubeclient.Actions() {
+		if patch, ok := action.(core_testing.PatchAction); ok && patch.GetResource().Resource == "cronjobs" {
+			if err := json.Unmarshal(patch.GetPatch(), &cj); err != nil {
+				t.Fatalf("decode applied cronjob: %v", err)
+			}
+		}
+	}
+	if cj.Spec.Schedule != "*/5 * * * *" || cj.Spec.ConcurrencyPolicy != batchv1.ForbidConcurrent {
+		t.Errorf("expected the schedule and concurrency policy of the app, got %q and %q", cj.Spec.Schedule, cj.Spec.ConcurrencyPolicy)
+	}
+	job := cj.Spec.JobTemplate.Spec
+	if job.Completions == nil || *job.Completions != 2 || job.Parallelism == nil || *job.Parallelism != 2 {
+		t.Errorf("expected the jobs to run 2 pods in parallel, got %+v", job)
+	}
+	if job.Template.Spec.RestartPolicy != core.RestartPolicyNever {
+		t.Errorf("expected the pods to run to completion, got restart policy %q", job.Template.Spec.RestartPolicy)
+	}
+}
+
+func TestRerunsJobWithNewImage(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 1)
+	app.Spec.Deployment.WorkloadKind = appcontrollerv1.JobWorkloadKind
+	app.Spec.Service = appcontrollerv1.ServiceObj{}
+	app.Spec.Ingress = appcontrollerv1.IngressObj{}
+	f.seedChildren(&appController{}, app)
+	app.Spec.Deployment.Image = "nginx:1.23"
+	app.Generation = 2
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+	// the delete is served by the object tracker
+	f.kubeobjects = append(f.kubeobjects, f.jobLister[0])
+
+	// the pod template of a job is immutable, the job is created again on its delete event
+	f.expectDeleteAction("jobs", app.Namespace, app.Spec.Deployment.Name)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+}
+
+func TestReportsCronJobRuns(t *testing.T) {
+	f := newFixture(t)
+	app := newCronApp("test", 1)
+	c := &appController{}
+	f.seedChildren(c, app)
+
+	succeeded := metav1.NewTime(time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC))
+	failed := metav1.NewTime(succeeded.Add(5 * time.Minute))
+	cj := f.cronJobLister[0]
+	cj.UID = "cronjob-uid"
+	cj.Status.LastScheduleTime = &failed
+	cj.Status.LastSuccessfulTime = &succeeded
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            cj.Name + "-1",
+			Namespace:       app.Namespace,
+			Labels:          cj.Spec.JobTemplate.Labels,
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(cj, batchv1.SchemeGroupVersion.WithKind("CronJob"))},
+		},
+		Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{{
+			Type:               batchv1.JobFailed,
+			Status:             core.ConditionTrue,
+			LastTransitionTime: failed,
+			Message:            "Job has reached the specified backoff limit",
+		}}},
+	}
+	f.jobLister = append(f.jobLister, job)
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+
+	status := f.updatedStatus()
+	if status.LastScheduleTime == nil || !status.LastScheduleTime.Equal(&failed) {
+		t.Errorf("expected last schedule time %v, got %v", failed, status.LastScheduleTime)
+	}
+	if status.LastSuccessfulTime == nil || !status.LastSuccessfulTime.Equal(&succeeded) {
+		t.Errorf("expected last successful time %v, got %v", succeeded, status.LastSuccessfulTime)
+	}
+	if status.LastFailureTime == nil || !status.LastFailureTime.Equal(&failed) {
+		t.Errorf("expected last failure time %v, got %v", failed, status.LastFailureTime)
+	}
+	cond := meta.FindStatusCondition(status.Conditions, appcontrollerv1.AppDeploymentAvailable)
+	if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != reasonFailed {
+		t.Errorf("expected the failed job to be reported, got %+v", cond)
+	}
+}
+
+func TestDefaultsChildNames(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 1)
+	app.Spec.Deployment.Name = ""
+	app.Spec.Service.Name = ""
+	app.Spec.Ingress.Name = ""
+	app.Status = appcontrollerv1.AppStatus{}
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	// an app admitted without the defaulting webhook
+	f.expectApplyAction("deployments", app.Namespace, "test-deploy")
+	f.expectApplyAction("services", app.Namespace, "test-svc")
+	f.expectApplyAction("ingresses", app.Namespace, "test-ingress")
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+}
+
+func TestAddsFinalizer(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 1)
+	app.Finalizers = nil
+	app.Status = appcontrollerv1.AppStatus{}
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	f.expectUpdateAppAction(app)
+	f.expectApplyAction("deployments", app.Namespace, app.Spec.Deployment.Name)
+	f.expectApplyAction("services", app.Namespace, app.Spec.Service.Name)
+	f.expectApplyAction("ingresses", app.Namespace, app.Spec.Ingress.Name)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+}
+
+func TestScalesDeployment(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 1)
+	f.seedChildren(&appController{}, app)
+	app.Spec.Deployment.Replicas = 5
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	f.expectApplyAction("deployments", app.Namespace, app.Spec.Deployment.Name)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+
+	// the applied deployment carries the new replica count
+	for _, action := range f.kubeclient.Actions() {
+		patch, ok := action.(core_testing.PatchAction)
+		if !ok || patch.GetResource().Resource != "deployments" {
+			continue
+		}
+		var deploy deployapps.Deployment
+		if err := json.Unmarshal(patch.GetPatch(), &deploy); err != nil {
+			t.Fatalf("decode applied deployment: %v", err)
+		}
+		if deploy.Spec.Replicas == nil || *deploy.Spec.Replicas != 5 {
+			t.Errorf("expected 5 replicas to be applied, got %v", deploy.Spec.Replicas)
+		}
+	}
+}
+
+// runDriftRepair syncs app after edit changed its seeded deployment by hand and returns the drift repair event,
+// empty when the deployment was left alone
+func runDriftRepair(t *testing.T, app *appcontrollerv1.App, edit func(deploy *deployapps.Deployment), repaired bool) string {
+	f := newFixture(t)
+	f.seedChildren(&appController{}, app)
+	edit(f.deploymentLister[0])
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	if repaired {
+		f.expectApplyAction("deployments", app.Namespace, app.Spec.Deployment.Name)
+	}
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+
+	for {
+		select {
+		case event := <-f.recorder.Events:
+			if strings.Contains(event, "Repaired drift") {
+				return event
+			}
+		default:
+			return ""
+		}
+	}
+}
+
+func TestRepairsDriftedPorts(t *testing.T) {
+	app := newApp("test", 1)
+	event := runDriftRepair(t, app, func(deploy *deployapps.Deployment) {
+		deploy.Spec.Template.Spec.Containers[0].Ports[0].ContainerPort = 8080
+	}, true)
+	if !strings.Contains(event, "spec.template.spec.containers[test-deploy].ports") {
+		t.Errorf("expected the ports drift to be repaired, got %q", event)
+	}
+}
+
+func TestIgnoresServerDefaults(t *testing.T) {
+	app := newApp("test", 1)
+	app.Spec.Deployment.ReadinessProbe = &core.Probe{ProbeHandler: core.ProbeHandler{
+		HTTPGet: &core.HTTPGetAction{Path: "/", Port: intstr.FromInt(80)},
+	}}
+	event := runDriftRepair(t, app, func(deploy *deployapps.Deployment) {
+		podSpec := &deploy.Spec.Template.Spec
+		podSpec.RestartPolicy = core.RestartPolicyAlways
+		podSpec.DNSPolicy = core.DNSClusterFirst
+		podSpec.SchedulerName = core.DefaultSchedulerName
+		podSpec.SecurityContext = &core.PodSecurityContext{}
+		container := &podSpec.Containers[0]
+		container.TerminationMessagePath = core.TerminationMessagePathDefault
+		container.TerminationMessagePolicy = core.TerminationMessageReadFile
+		container.ReadinessProbe.HTTPGet.Scheme = core.URISchemeHTTP
+		container.ReadinessProbe.TimeoutSeconds = 1
+		container.ReadinessProbe.PeriodSeconds = 10
+		container.ReadinessProbe.SuccessThreshold = 1
+		container.ReadinessProbe.FailureThreshold = 3
+	}, false)
+	if event != "" {
+		t.Errorf("expected the server defaults to be left alone, got %q", event)
+	}
+}
+
+// newContainerApp returns an app setting every container field the controller applies
+func newContainerApp(name string) *appcontrollerv1.App {
+	app := newApp(name, 1)
+	app.Spec.Deployment.ImagePullPolicy = core.PullIfNotPresent
+	app.Spec.Deployment.Env = []core.EnvVar{{Name: "MODE", Value: "production"}}
+	app.Spec.Deployment.Resources = core.ResourceRequirements{
+		Requests: core.ResourceList{core.ResourceCPU: resource.MustParse("100m")},
+	}
+	app.Spec.Deployment.ReadinessProbe = &core.Probe{ProbeHandler: core.ProbeHandler{
+		HTTPGet: &core.HTTPGetAction{Path: "/", Port: intstr.FromInt(80)},
+	}}
+	app.Spec.Deployment.Volumes = []core.Volume{{
+		Name:         "cache",
+		VolumeSource: core.VolumeSource{EmptyDir: &core.EmptyDirVolumeSource{}},
+	}}
+	app.Spec.Deployment.VolumeMounts = []core.VolumeMount{{Name: "cache", MountPath: "/var/cache/nginx"}}
+	return app
+}
+
+func TestRepairsDriftedContainerFields(t *testing.T) {
+	container := func(deploy *deployapps.Deployment) *core.Container {
+		return &deploy.Spec.Template.Spec.Containers[0]
+	}
+	for _, tc := range []struct {
+		field string
+		edit  func(deploy *deployapps.Deployment)
+	}{
+		{"spec.template.spec.containers[test-deploy].env", func(deploy *deployapps.Deployment) {
+			container(deploy).Env[0].Value = "debug"
+		}},
+		{"spec.template.spec.containers[test-deploy].resources", func(deploy *deployapps.Deployment) {
+			container(deploy).Resources.Requests[core.ResourceCPU] = resource.MustParse("1")
+		}},
+		{"spec.template.spec.containers[test-deploy].readinessProbe", func(deploy *deployapps.Deployment) {
+			container(deploy).ReadinessProbe = nil
+		}},
+		{"spec.template.spec.volumes", func(deploy *deployapps.Deployment) {
+			deploy.Spec.Template.Spec.Volumes[0].EmptyDir = nil
+			deploy.Spec.Template.Spec.Volumes[0].HostPath = &core.HostPathVolumeSource{Path: "/tmp"}
+		}},
+		{"spec.template.spec.containers[test-deploy].volumeMounts", func(deploy *deployapps.Deployment) {
+			container(deploy).VolumeMounts[0].MountPath = "/tmp"
+		}},
+		{"spec.template.spec.containers[test-deploy].imagePullPolicy", func(deploy *deployapps.Deployment) {
+			container(deploy).ImagePullPolicy = core.PullNever
+		}},
+	} {
+		event := runDriftRepair(t, newContainerApp("test"), tc.edit, true)
+		if !strings.Contains(event, tc.field) {
+			t.Errorf("expected the %s drift to be repaired, got %q", tc.field, event)
+		}
+	}
+}
+
+func TestKeepsFieldsAddedByOtherManagers(t *testing.T) {
+	// e.g. a sidecar injector adding its own env var, mount and container
+	event := runDriftRepair(t, newContainerApp("test"), func(deploy *deployapps.Deployment) {
+		podSpec := &deploy.Spec.Template.Spec
+		podSpec.Containers[0].Env = append(podSpec.Containers[0].Env, core.EnvVar{Name: "PROXY", Value: "on"})
+		podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, core.VolumeMount{Name: "proxy", MountPath: "/proxy"})
+		podSpec.Containers = append(podSpec.Containers, core.Container{Name: "proxy", Image: "proxy:1.0"})
+	}, false)
+	if event != "" {
+		t.Errorf("expected the fields of other managers to be left alone, got %q", event)
+	}
+}
+
+func TestPausedAppReportsContainerDrift(t *testing.T) {
+	f := newFixture(t)
+	app := newContainerApp("test")
+	app.Spec.Paused = true
+	f.seedChildren(&appController{}, app)
+	f.deploymentLister[0].Spec.Template.Spec.Containers[0].Env[0].Value = "debug"
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+
+	status := f.updatedStatus()
+	if len(status.Drift) != 1 || status.Drift[0] != "deployment test-deploy: spec.template.spec.containers[test-deploy].env" {
+		t.Errorf("expected the env drift in status, got %v", status.Drift)
+	}
+}
+
+func TestPausedAppReportsDrift(t *testing.T) {
+	for _, pause := range []func(app *appcontrollerv1.App){
+		func(app *appcontrollerv1.App) { app.Spec.Paused = true },
+		func(app *appcontrollerv1.App) { app.Annotations = map[string]string{pausedAnnotation: "true"} },
+	} {
+		f := newFixture(t)
+		app := newApp("test", 1)
+		f.seedChildren(&appController{}, app)
+		app.Spec.Deployment.Replicas = 5
+		app.Generation = 2
+		pause(app)
+
+		f.appLister = append(f.appLister, app)
+		f.objects = append(f.objects, app)
+
+		// no child is written
+		f.expectUpdateAppStatusAction(app)
+
+		f.run(getKey(app, t))
+
+		status := f.updatedStatus()
+		if len(status.Drift) != 1 || status.Drift[0] != "deployment test-deploy: spec.replicas" {
+			t.Errorf("expected the replicas drift in status, got %v", status.Drift)
+		}
+		if status.ObservedGeneration != 1 {
+			t.Errorf("expected generation 2 to stay pending, got observed generation %d", status.ObservedGeneration)
+		}
+		if !meta.IsStatusConditionTrue(status.Conditions, appcontrollerv1.AppPaused) {
+			t.Errorf("expected the Paused condition, got %+v", status.Conditions)
+		}
+	}
+}
+
+func TestResumedAppAppliesSpec(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 1)
+	f.seedChildren(&appController{}, app)
+	app.Status.Drift = []string{"deployment test-deploy: spec.replicas"}
+	app.Status.Conditions = []metav1.Condition{{Type: appcontrollerv1.AppPaused, Status: metav1.ConditionTrue, Reason: reasonPaused}}
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	// the children may have been edited by hand, everything is applied again
+	f.expectApplyAction("deployments", app.Namespace, app.Spec.Deployment.Name)
+	f.expectApplyAction("services", app.Namespace, app.Spec.Service.Name)
+	f.expectApplyAction("ingresses", app.Namespace, app.Spec.Ingress.Name)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+
+	status := f.updatedStatus()
+	if len(status.Drift) != 0 || meta.FindStatusCondition(status.Conditions, appcontrollerv1.AppPaused) != nil {
+		t.Errorf("expected the pause to be cleared from status, got drift %v and conditions %+v", status.Drift, status.Conditions)
+	}
+}
+
+func TestDisablesService(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 1)
+	f.seedChildren(&appController{}, app)
+	app.Spec.Service.Enabled = false
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	// the ingress routes to the service and goes away with it
+	f.expectDeleteAction("services", app.Namespace, app.Spec.Service.Name)
+	f.expectDeleteAction("ingresses", app.Namespace, app.Spec.Ingress.Name)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+}
+
+func TestDisablesIngress(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 1)
+	f.seedChildren(&appController{}, app)
+	app.Spec.Ingress.Enabled = false
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	f.expectDeleteAction("ingresses", app.Namespace, app.Spec.Ingress.Name)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+}
+
+func TestEnablesIngress(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 1)
+	app.Spec.Ingress.Enabled = false
+	f.seedChildren(&appController{}, app)
+	app.Spec.Ingress.Enabled = true
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	f.expectApplyAction("ingresses", app.Namespace, app.Spec.Ingress.Name)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+}
+
+func TestDeletesChildrenOfDeletedApp(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 1)
+	f.seedChildren(&appController{}, app)
+	now := metav1.Now()
+	app.DeletionTimestamp = &now
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	// the finalizer stays until the deletions are observed
+	f.expectDeleteAction("ingresses", app.Namespace, app.Spec.Ingress.Name)
+	f.expectDeleteAction("services", app.Namespace, app.Spec.Service.Name)
+	f.expectDeleteAction("deployments", app.Namespace, app.Spec.Deployment.Name)
+
+	f.run(getKey(app, t))
+}
+
+func TestReleasesFinalizerOfDeletedApp(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 1)
+	now := metav1.Now()
+	app.DeletionTimestamp = &now
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	f.expectUpdateAppAction(app)
+
+	f.run(getKey(app, t))
+}
+
+func TestOrphansChildrenOfDeletedApp(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 1)
+	f.seedChildren(&appController{}, app)
+	app.Spec.DeletionPolicy = appcontrollerv1.DeletionPolicyOrphan
+	now := metav1.Now()
+	app.DeletionTimestamp = &now
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+	// merge patches are served by the object tracker, the children have to exist there
+	for _, deploy := range f.deploymentLister {
+		f.kubeobjects = append(f.kubeobjects, deploy)
+	}
+	for _, svc := range f.serviceLister {
+		f.kubeobjects = append(f.kubeobjects, svc)
+	}
+	for _, ingress := range f.ingressLister {
+		f.kubeobjects = append(f.kubeobjects, ingress)
+	}
+
+	f.expectMergePatchAction("ingresses", app.Namespace, app.Spec.Ingress.Name)
+	f.expectMergePatchAction("services", app.Namespace, app.Spec.Service.Name)
+	f.expectMergePatchAction("deployments", app.Namespace, app.Spec.Deployment.Name)
+	f.expectUpdateAppAction(app)
+
+	f.run(getKey(app, t))
+}
+
+func TestIgnoresDeletedApp(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 1)
+
+	f.run(getKey(app, t))
+}
+
+func TestRejectsInvalidSpec(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 1)
+	app.Spec.Service.Headless = true
+	app.Spec.Service.Type = core.ServiceTypeNodePort
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+}
+
+func TestRefusesForeignDeployment(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 1)
+	foreign := (&appController{}).constructDeployment(app, 0)
+	foreign.OwnerReferences = nil
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+	f.deploymentLister = append(f.deploymentLister, foreign)
+
+	f.expectUpdateAppStatusAction(app)
+
+	f.runExpectError(getKey(app, t))
+}
+
+func TestAppliesContainerSpec(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 1)
+	app.Spec.Service.Enabled = false
+	app.Spec.Deployment.ImagePullPolicy = core.PullIfNotPresent
+	app.Spec.Deployment.Args = []string{"-g", "daemon off;"}
+	app.Spec.Deployment.Env = []core.EnvVar{{
+		Name: "PASSWORD",
+		ValueFrom: &core.EnvVarSource{SecretKeyRef: &core.SecretKeySelector{
+			LocalObjectReference: core.LocalObjectReference{Name: "test-secret"},
+			Key:                  "password",
+		}},
+	}}
+	app.Spec.Deployment.ReadinessProbe = &core.Probe{ProbeHandler: core.ProbeHandler{
+		HTTPGet: &core.HTTPGetAction{Path: "/", Port: intstr.FromInt(80)},
+	}}
+	app.Spec.Deployment.Volumes = []core.Volume{{
+		Name:         "cache",
+		VolumeSource: core.VolumeSource{EmptyDir: &core.EmptyDirVolumeSource{}},
+	}}
+	app.Spec.Deployment.VolumeMounts = []core.VolumeMount{{Name: "cache", MountPath: "/var/cache/nginx"}}
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	f.expectApplyAction("deployments", app.Namespace, app.Spec.Deployment.Name)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+
+	patch := f.kubeclient.Actions()[0].(core_testing.PatchAction)
+	var deploy deployapps.Deployment
+	if err := json.Unmarshal(patch.GetPatch(), &deploy); err != nil {
+		t.Fatalf("decode applied deployment: %v", err)
+	}
+	podSpec := deploy.Spec.Template.Spec
+	container := podSpec.Containers[0]
+	if container.ImagePullPolicy != core.PullIfNotPresent {
+		t.Errorf("expected image pull policy IfNotPresent, got %q", container.ImagePullPolicy)
+	}
+	if !stringsEqual(container.Args, app.Spec.Deployment.Args) {
+		t.Errorf("expected args %v, got %v", app.Spec.Deployment.Args, container.Args)
+	}
+	if len(container.Env) != 1 || container.Env[0].ValueFrom == nil || container.Env[0].ValueFrom.SecretKeyRef == nil {
+		t.Errorf("expected env from secret, got %+v", container.Env)
+	}
+	if container.ReadinessProbe == nil || container.ReadinessProbe.HTTPGet == nil {
+		t.Errorf("expected http readiness probe, got %+v", container.ReadinessProbe)
+	}
+	if len(container.VolumeMounts) != 1 || len(podSpec.Volumes) != 1 || podSpec.Volumes[0].EmptyDir == nil {
+		t.Errorf("expected cache volume to be mounted, got mounts %+v volumes %+v", container.VolumeMounts, podSpec.Volumes)
+	}
+}
+
+func TestPrunesRenamedChildren(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 1)
+	f.seedChildren(&appController{}, app)
+	app.Spec.Deployment.Name = "test-deploy-v2"
+	app.Spec.Service.Name = "test-svc-v2"
+	app.Generation = 2
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	// the replacements are applied before the stale children are deleted
+	f.expectApplyAction("deployments", app.Namespace, "test-deploy-v2")
+	f.expectApplyAction("services", app.Namespace, "test-svc-v2")
+	f.expectApplyAction("ingresses", app.Namespace, app.Spec.Ingress.Name)
+	f.expectDeleteAction("services", app.Namespace, "test-svc")
+	f.expectDeleteAction("deployments", app.Namespace, "test-deploy")
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+}
+
+func TestPruneDryRunKeepsRenamedChildren(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 1)
+	f.seedChildren(&appController{}, app)
+	app.Spec.Deployment.Name = "test-deploy-v2"
+	app.Generation = 2
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	f.expectApplyAction("deployments", app.Namespace, "test-deploy-v2")
+	f.expectApplyAction("services", app.Namespace, app.Spec.Service.Name)
+	f.expectApplyAction("ingresses", app.Namespace, app.Spec.Ingress.Name)
+	f.expectUpdateAppStatusAction(app)
+
+	c := f.newController()
+	c.pruneDryRun = true
+	if err := c.syncHandler(getKey(app, t)); err != nil {
+		t.Errorf("error syncing app: %v", err)
+	}
+	checkActions(t, "app", f.actions, f.client.Actions())
+	checkActions(t, "kube", f.kubeactions, f.kubeclient.Actions())
+}
+
+// newComponentApp returns an app written as v2 with a web component and a worker component behind its own service
+func newComponentApp(name string) *appcontrollerv1.App {
+	app := newApp(name, 1)
+	app.Annotations = map[string]string{appcontrollerv2.ComponentsAnnotation: `{"name":"web","extra":[{"name":"worker",` +
+		`"workload":{"image":"busybox:1.36","replicas":2},"service":{"enabled":true}}]}`}
+	return app
+}
+
+func TestCreatesComponentChildren(t *testing.T) {
+	f := newFixture(t)
+	app := newComponentApp("test")
+	app.Status = appcontrollerv1.AppStatus{}
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	f.expectApplyAction("deployments", app.Namespace, app.Spec.Deployment.Name)
+	f.expectApplyAction("services", app.Namespace, app.Spec.Service.Name)
+	f.expectApplyAction("ingresses", app.Namespace, app.Spec.Ingress.Name)
+	// the children of the worker are named after the app and the component
+	f.expectApplyAction("deployments", app.Namespace, "test-worker")
+	f.expectApplyAction("services", app.Namespace, "test-worker-svc")
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+
+	status := f.updatedStatus()
+	if len(status.Components) != 1 {
+		t.Fatalf("expected the status of the worker component, got %+v", status.Components)
+	}
+	worker := status.Components[0]
+	if worker.Name != "worker" || worker.WorkloadName != "test-worker" || worker.ServiceName != "test-worker-svc" {
+		t.Errorf("unexpected worker status %+v", worker)
+	}
+	// the worker pods are selected apart from the web pods
+	if worker.Selector != "app=test,controller=test-worker" {
+		t.Errorf("unexpected worker selector %q", worker.Selector)
+	}
+	if meta.IsStatusConditionTrue(status.Conditions, appcontrollerv1.AppReady) {
+		t.Errorf("expected the app not to be ready before its components")
+	}
+}
+
+func TestKeepsComponentChildren(t *testing.T) {
+	f := newFixture(t)
+	app := newComponentApp("test")
+	c := &appController{}
+	f.seedChildren(c, app)
+	components, err := componentApps(app)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.seedChildren(c, components[0])
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	// nothing is pruned, the worker children are named by the worker component
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+}
+
+func TestDropsChildrenOfRemovedComponent(t *testing.T) {
+	f := newFixture(t)
+	app := newComponentApp("test")
+	c := &appController{}
+	f.seedChildren(c, app)
+	components, err := componentApps(app)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.seedChildren(c, components[0])
+	// the components live in an annotation, removing one leaves the generation as it is
+	delete(app.Annotations, appcontrollerv2.ComponentsAnnotation)
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	f.expectDeleteAction("services", app.Namespace, "test-worker-svc")
+	f.expectDeleteAction("deployments", app.Namespace, "test-worker")
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+}
+
+func TestCreatesAutoscaler(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 1)
+	f.seedChildren(&appController{}, app)
+	cpu := int32(70)
+	app.Spec.Autoscaling = appcontrollerv1.AutoscalingObj{Enabled: true, MaxReplicas: 10, TargetCPUUtilizationPercentage: &cpu}
+	app.Generation = 2
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	f.expectApplyAction("deployments", app.Namespace, app.Spec.Deployment.Name)
+	f.expectApplyAction("services", app.Namespace, app.Spec.Service.Name)
+	f.expectApplyAction("ingresses", app.Namespace, app.Spec.Ingress.Name)
+	f.expectApplyAction("horizontalpodautoscalers", app.Namespace, app.Spec.Deployment.Name)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+
+	// replicas are left to the autoscaler
+	var deploy deployapps.Deployment
+	if err := json.Unmarshal(f.kubeclient.Actions()[0].(core_testing.PatchAction).GetPatch(), &deploy); err != nil {
+		t.Fatalf("decode applied deployment: %v", err)
+	}
+	if deploy.Spec.Replicas != nil {
+		t.Errorf("expected replicas to be left out of the applied deployment, got %d", *deploy.Spec.Replicas)
+	}
+	var hpa autoscaling.HorizontalPodAutoscaler
+	if err := json.Unmarshal(f.kubeclient.Actions()[3].(core_testing.PatchAction).GetPatch(), &hpa); err != nil {
+		t.Fatalf("decode applied horizontalpodautoscaler: %v", err)
+	}
+	if hpa.Spec.ScaleTargetRef.Name != app.Spec.Deployment.Name || hpa.Spec.MaxReplicas != 10 || len(hpa.Spec.Metrics) != 1 {
+		t.Errorf("unexpected horizontalpodautoscaler spec %+v", hpa.Spec)
+	}
+}
+
+func TestHandsReplicasOverToAutoscaler(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 3)
+	f.seedChildren(&appController{}, app)
+	// the fixed replicas were applied by the controller
+	f.deploymentLister[0].ManagedFields = []metav1.ManagedFieldsEntry{{
+		Manager:    DefaultFieldManager,
+		Operation:  metav1.ManagedFieldsOperationApply,
+		FieldsType: "FieldsV1",
+		FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:replicas":{},"f:template":{}}}`)},
+	}}
+	app.Spec.Autoscaling = appcontrollerv1.AutoscalingObj{Enabled: true, MaxReplicas: 10}
+	app.Generation = 2
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	f.expectApplyAction("deployments", app.Namespace, app.Spec.Deployment.Name)
+	f.expectApplyAction("deployments", app.Namespace, app.Spec.Deployment.Name)
+	f.expectApplyAction("services", app.Namespace, app.Spec.Service.Name)
+	f.expectApplyAction("ingresses", app.Namespace, app.Spec.Ingress.Name)
+	f.expectApplyAction("horizontalpodautoscalers", app.Namespace, app.Spec.Deployment.Name)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+
+	// the live replicas are handed over before the deployment is applied without them
+	var handover, deploy deployapps.Deployment
+	if err := json.Unmarshal(f.kubeclient.Actions()[0].(core_testing.PatchAction).GetPatch(), &handover); err != nil {
+		t.Fatalf("decode handover: %v", err)
+	}
+	if handover.Spec.Replicas == nil || *handover.Spec.Replicas != 3 || len(handover.Spec.Template.Spec.Containers) != 0 {
+		t.Errorf("expected only the 3 live replicas to be handed over, got %+v", handover.Spec)
+	}
+	if err := json.Unmarshal(f.kubeclient.Actions()[1].(core_testing.PatchAction).GetPatch(), &deploy); err != nil {
+		t.Fatalf("decode applied deployment: %v", err)
+	}
+	if deploy.Spec.Replicas != nil {
+		t.Errorf("expected replicas to be left out of the applied deployment, got %d", *deploy.Spec.Replicas)
+	}
+}
+
+func TestHandsStatefulSetReplicasOverToAutoscaler(t *testing.T) {
+	f := newFixture(t)
+	app := newStatefulApp("test", 3)
+	f.seedChildren(&appController{}, app)
+	f.statefulSetLister[0].ManagedFields = []metav1.ManagedFieldsEntry{{
+		Manager:    DefaultFieldManager,
+		Operation:  metav1.ManagedFieldsOperationApply,
+		FieldsType: "FieldsV1",
+		FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:replicas":{},"f:template":{}}}`)},
+	}}
+	app.Spec.Autoscaling = appcontrollerv1.AutoscalingObj{Enabled: true, MaxReplicas: 10}
+	app.Generation = 2
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	f.expectApplyAction("services", app.Namespace, "test-deploy-headless")
+	f.expectApplyAction("statefulsets", app.Namespace, app.Spec.Deployment.Name)
+	f.expectApplyAction("statefulsets", app.Namespace, app.Spec.Deployment.Name)
+	f.expectApplyAction("services", app.Namespace, app.Spec.Service.Name)
+	f.expectApplyAction("ingresses", app.Namespace, app.Spec.Ingress.Name)
+	f.expectApplyAction("horizontalpodautoscalers", app.Namespace, app.Spec.Deployment.Name)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+
+	var handover, sts deployapps.StatefulSet
+	if err := json.Unmarshal(f.kubeclient.Actions()[1].(core_testing.PatchAction).GetPatch(), &handover); err != nil {
+		t.Fatalf("decode handover: %v", err)
+	}
+	if handover.Kind != "StatefulSet" || handover.Spec.Replicas == nil || *handover.Spec.Replicas != 3 {
+		t.Errorf("expected the 3 live replicas of the statefulset to be handed over, got %+v", handover)
+	}
+	if err := json.Unmarshal(f.kubeclient.Actions()[2].(core_testing.PatchAction).GetPatch(), &sts); err != nil {
+		t.Fatalf("decode applied statefulset: %v", err)
+	}
+	if sts.Spec.Replicas != nil {
+		t.Errorf("expected replicas to be left out of the applied statefulset, got %d", *sts.Spec.Replicas)
+	}
+}
+
+func TestIgnoresReplicasOfAutoscaledDeployment(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 1)
+	app.Spec.Autoscaling = appcontrollerv1.AutoscalingObj{Enabled: true, MaxReplicas: 10}
+	f.seedChildren(&appController{}, app)
+	// scaled by the autoscaler
+	replicas := int32(4)
+	f.deploymentLister[0].Spec.Replicas = &replicas
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+}
+
+func TestDeletesAutoscalerWhenDisabled(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 1)
+	app.Spec.Autoscaling = appcontrollerv1.AutoscalingObj{Enabled: true, MaxReplicas: 10}
+	f.seedChildren(&appController{}, app)
+	app.Spec.Autoscaling.Enabled = false
+	app.Generation = 2
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	// the deployment takes its replicas from the spec again
+	f.expectApplyAction("deployments", app.Namespace, app.Spec.Deployment.Name)
+	f.expectApplyAction("services", app.Namespace, app.Spec.Service.Name)
+	f.expectApplyAction("ingresses", app.Namespace, app.Spec.Ingress.Name)
+	f.expectDeleteAction("horizontalpodautoscalers", app.Namespace, app.Spec.Deployment.Name)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+}
+
+func TestCreatesDisruptionBudget(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 3)
+	app.Spec.Service.Enabled = false
+	f.seedChildren(&appController{}, app)
+	maxUnavailable := intstr.FromString("25%")
+	app.Spec.DisruptionBudget = appcontrollerv1.DisruptionBudgetObj{Enabled: true, MaxUnavailable: &maxUnavailable}
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	f.expectApplyAction("poddisruptionbudgets", app.Namespace, app.Spec.Deployment.Name)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+
+	var pdb policy.PodDisruptionBudget
+	if err := json.Unmarshal(f.kubeclient.Actions()[0].(core_testing.PatchAction).GetPatch(), &pdb); err != nil {
+		t.Fatalf("decode applied poddisruptionbudget: %v", err)
+	}
+	if pdb.Spec.MinAvailable != nil || pdb.Spec.MaxUnavailable == nil || pdb.Spec.MaxUnavailable.String() != "25%" {
+		t.Errorf("unexpected poddisruptionbudget limits %+v", pdb.Spec)
+	}
+	if !equality.Semantic.DeepEqual(pdb.Spec.Selector, f.deploymentLister[0].Spec.Selector) {
+		t.Errorf("expected the poddisruptionbudget to select the deployment pods, got %v", pdb.Spec.Selector)
+	}
+}
+
+func TestDeletesDisruptionBudgetWhenDisabled(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 3)
+	app.Spec.Service.Enabled = false
+	app.Spec.DisruptionBudget.Enabled = true
+	f.seedChildren(&appController{}, app)
+	app.Spec.DisruptionBudget.Enabled = false
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	f.expectDeleteAction("poddisruptionbudgets", app.Namespace, app.Spec.Deployment.Name)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+}
+
+func TestRollsOutOnConfigChange(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 1)
+	app.Spec.Service.Enabled = false
+	f.seedChildren(&appController{}, app)
+	app.Spec.Deployment.ConfigMaps = []string{"test-config"}
+	app.Spec.Deployment.Secrets = []string{"test-secret"}
+	// rolled out with the previous content
+	f.deploymentLister[0].Spec.Template.Annotations = map[string]string{configHashAnnotation: "stale"}
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+	f.configMapLister = append(f.configMapLister, &core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: app.Namespace},
+		Data:       map[string]string{"level": "debug"},
+	})
+	f.secretLister = append(f.secretLister, &core.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-secret", Namespace: app.Namespace},
+		Data:       map[string][]byte{"password": []byte("secret")},
+	})
+
+	f.expectApplyAction("deployments", app.Namespace, app.Spec.Deployment.Name)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+
+	var deploy deployapps.Deployment
+	if err := json.Unmarshal(f.kubeclient.Actions()[0].(core_testing.PatchAction).GetPatch(), &deploy); err != nil {
+		t.Fatalf("decode applied deployment: %v", err)
+	}
+	if hash := deploy.Spec.Template.Annotations[configHashAnnotation]; hash == "" || hash == "stale" {
+		t.Errorf("expected a new config hash on the pod template, got %q", hash)
+	}
+}
+
+func TestConfigHashFollowsContent(t *testing.T) {
+	app := newApp("test", 1)
+	app.Spec.Deployment.ConfigMaps = []string{"test-config"}
+	hash := func(level string) string {
+		f := newFixture(t)
+		f.configMapLister = append(f.configMapLister, &core.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: app.Namespace},
+			Data:       map[string]string{"level": level},
+		})
+		return f.newController().configHash(app)
+	}
+
+	if hash("debug") != hash("debug") {
+		t.Errorf("expected the config hash to be stable")
+	}
+	if hash("debug") == hash("info") {
+		t.Errorf("expected the config hash to change with the configmap content")
+	}
+}
+
+func TestEnqueuesAppsReferencingConfig(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 1)
+	app.Spec.Deployment.Secrets = []string{"test-secret"}
+	other := newApp("other", 1)
+	f.appLister = append(f.appLister, app, other)
+	c := f.newController()
+
+	c.secretEvent(&core.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test-secret", Namespace: app.Namespace}})
+	c.configMapEvent(&core.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-secret", Namespace: app.Namespace}})
+
+	if c.queue.Len() != 1 {
+		t.Fatalf("expected 1 app to be enqueued, got %d", c.queue.Len())
+	}
+	if key, _ := c.queue.Get(); key != getKey(app, t) {
+		t.Errorf("expected %s to be enqueued, got %v", getKey(app, t), key)
+	}
+}
+
+func TestEnqueuesAppOnConfigMapUpdate(t *testing.T) {
+	f := newFixture(t)
+	app := newApp("test", 1)
+	app.Spec.Deployment.ConfigMaps = []string{"test-config"}
+	f.appLister = append(f.appLister, app)
+	c := f.newController()
+
+	old := &core.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test-config", Namespace: app.Namespace, ResourceVersion: "1"}}
+	update := c.updateConfigEvent(c.configMapEvent)
+	// periodic resync
+	update(old, old)
+	if c.queue.Len() != 0 {
+		t.Fatalf("expected a resync to enqueue nothing, got %d", c.queue.Len())
+	}
+	changed := old.DeepCopy()
+	changed.ResourceVersion = "2"
+	changed.Data = map[string]string{"level": "debug"}
+	update(old, changed)
+	if c.queue.Len() != 1 {
+		t.Fatalf("expected 1 app to be enqueued, got %d", c.queue.Len())
+	}
+	if key, _ := c.queue.Get(); key != getKey(app, t) {
+		t.Errorf("expected %s to be enqueued, got %v", getKey(app, t), key)
+	}
+}
+
+func TestStartsCanaryRollout(t *testing.T) {
+	f := newFixture(t)
+	app := newRolloutApp("test", "nginx:1.0", "nginx:1.0")
+	app.Status.Rollout = nil
+	f.seedChildren(&appController{}, app)
+	app.Spec.Deployment.Image = "nginx:1.1"
+	app.Generation = 2
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	// the stable deployment keeps its image, the canary takes the new one
+	f.expectApplyAction("deployments", app.Namespace, app.Spec.Deployment.Name)
+	f.expectApplyAction("services", app.Namespace, app.Spec.Service.Name)
+	f.expectApplyAction("ingresses", app.Namespace, app.Spec.Ingress.Name)
+	f.expectApplyAction("deployments", app.Namespace, app.Spec.Deployment.Name+canarySuffix)
+	f.expectApplyAction("services", app.Namespace, app.Spec.Service.Name+canarySuffix)
+	f.expectApplyAction("ingresses", app.Namespace, app.Spec.Ingress.Name+canarySuffix)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+
+	rollout := f.updatedStatus().Rollout
+	if rollout == nil || rollout.Phase != appcontrollerv1.RolloutProgressing ||
+		rollout.StableImage != "nginx:1.0" || rollout.UpdatedImage != "nginx:1.1" || rollout.CurrentWeight != 0 {
+		t.Errorf("unexpected rollout status %+v", rollout)
+	}
+	for i, image := range []string{"nginx:1.0", "nginx:1.1"} {
+		var deploy deployapps.Deployment
+		if err := json.Unmarshal(f.kubeclient.Actions()[i*3].(core_testing.PatchAction).GetPatch(), &deploy); err != nil {
+			t.Fatalf("decode applied deployment: %v", err)
+		}
+		if applied := deploy.Spec.Template.Spec.Containers[0].Image; applied != image {
+			t.Errorf("expected deployment %s to run %s, got %s", deploy.Name, image, applied)
+		}
+	}
+	var ingress net.Ingress
+	if err := json.Unmarshal(f.kubeclient.Actions()[5].(core_testing.PatchAction).GetPatch(), &ingress); err != nil {
+		t.Fatalf("decode applied ingress: %v", err)
+	}
+	// no traffic goes to the canary before it is available
+	if ingress.Annotations[nginxCanaryWeightAnnotation] != "0" {
+		t.Errorf("expected a canary weight of 0, got %q", ingress.Annotations[nginxCanaryWeightAnnotation])
+	}
+}
+
+func TestHoldsCanaryWeightUntilAvailable(t *testing.T) {
+	f := newFixture(t)
+	app := newRolloutApp("test", "nginx:1.0", "nginx:1.1")
+	app.Status.Rollout.Phase = appcontrollerv1.RolloutPaused
+	app.Status.Rollout.CurrentWeight = 20
+	c := &appController{}
+	f.seedChildren(c, app)
+	f.seedRolloutChildren(c, app)
+	// the canary pods are restarting
+	f.deploymentLister[len(f.deploymentLister)-1].Status.ReadyReplicas = 0
+	app.Annotations = map[string]string{promoteAnnotation: "true"}
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	// the command is consumed before the sync acts on it
+	f.expectPatchAppAction(app)
+	f.expectApplyAction("deployments", app.Namespace, app.Spec.Deployment.Name+canarySuffix)
+	f.expectApplyAction("services", app.Namespace, app.Spec.Service.Name+canarySuffix)
+	f.expectApplyAction("ingresses", app.Namespace, app.Spec.Ingress.Name+canarySuffix)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+
+	if rollout := f.updatedStatus().Rollout; rollout.Phase != appcontrollerv1.RolloutProgressing ||
+		rollout.CurrentStep != 1 || rollout.CurrentWeight != 20 {
+		t.Errorf("expected step 1 to wait for the canary at the weight of step 0, got %+v", rollout)
+	}
+	var ingress net.Ingress
+	if err := json.Unmarshal(f.kubeclient.Actions()[2].(core_testing.PatchAction).GetPatch(), &ingress); err != nil {
+		t.Fatalf("decode applied ingress: %v", err)
+	}
+	if ingress.Annotations[nginxCanaryWeightAnnotation] != "20" {
+		t.Errorf("expected the canary weight to stay at 20, got %q", ingress.Annotations[nginxCanaryWeightAnnotation])
+	}
+}
+
+func TestPausesCanaryStep(t *testing.T) {
+	f := newFixture(t)
+	app := newRolloutApp("test", "nginx:1.0", "nginx:1.1")
+	c := &appController{}
+	f.seedChildren(c, app)
+	f.seedRolloutChildren(c, app)
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	f.expectApplyAction("deployments", app.Namespace, app.Spec.Deployment.Name+canarySuffix)
+	f.expectApplyAction("services", app.Namespace, app.Spec.Service.Name+canarySuffix)
+	f.expectApplyAction("ingresses", app.Namespace, app.Spec.Ingress.Name+canarySuffix)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+
+	// the first step has no pause and waits for promotion
+	if rollout := f.updatedStatus().Rollout; rollout.Phase != appcontrollerv1.RolloutPaused || rollout.CurrentStep != 0 {
+		t.Errorf("expected the rollout to pause at step 0, got %+v", rollout)
+	}
+}
+
+func TestPromotesCanaryStep(t *testing.T) {
+	f := newFixture(t)
+	app := newRolloutApp("test", "nginx:1.0", "nginx:1.1")
+	app.Status.Rollout.Phase = appcontrollerv1.RolloutPaused
+	c := &appController{}
+	f.seedChildren(c, app)
+	f.seedRolloutChildren(c, app)
+	app.Annotations = map[string]string{promoteAnnotation: "true"}
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	// the command is consumed before the sync acts on it
+	f.expectPatchAppAction(app)
+	f.expectApplyAction("deployments", app.Namespace, app.Spec.Deployment.Name+canarySuffix)
+	f.expectApplyAction("services", app.Namespace, app.Spec.Service.Name+canarySuffix)
+	f.expectApplyAction("ingresses", app.Namespace, app.Spec.Ingress.Name+canarySuffix)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+
+	if rollout := f.updatedStatus().Rollout; rollout.Phase != appcontrollerv1.RolloutPaused ||
+		rollout.CurrentStep != 1 || rollout.CurrentWeight != 50 {
+		t.Errorf("expected the rollout to pause at step 1, got %+v", rollout)
+	}
+}
+
+func TestConsumesPromoteOfFailedStatusUpdate(t *testing.T) {
+	f := newFixture(t)
+	app := newRolloutApp("test", "nginx:1.0", "nginx:1.1")
+	app.Status.Rollout.Phase = appcontrollerv1.RolloutPaused
+	c := &appController{}
+	f.seedChildren(c, app)
+	f.seedRolloutChildren(c, app)
+	app.Annotations = map[string]string{promoteAnnotation: "true"}
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	ctl := f.newController()
+	f.client.PrependReactor("update", "apps", func(action core_testing.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "status" {
+			return false, nil, nil
+		}
+		return true, nil, errors.NewConflict(appcontrollerv1.Resource("apps"), app.Name, nil)
+	})
+	if err := ctl.syncHandler(getKey(app, t)); err == nil {
+		t.Fatalf("expected the failed status update to fail the sync")
+	}
+
+	// the retry finds no promote command left to move the rollout one more step
+	obj, err := f.client.Tracker().Get(appcontrollerv1.SchemeGroupVersion.WithResource("apps"), app.Namespace, app.Name)
+	if err != nil {
+		t.Fatalf("get app: %v", err)
+	}
+	if _, ok := obj.(*appcontrollerv1.App).Annotations[promoteAnnotation]; ok {
+		t.Errorf("expected the promote command to be consumed")
+	}
+}
+
+func TestCompletesCanaryRollout(t *testing.T) {
+	f := newFixture(t)
+	app := newRolloutApp("test", "nginx:1.0", "nginx:1.1")
+	app.Status.Rollout.Phase = appcontrollerv1.RolloutPaused
+	app.Status.Rollout.CurrentStep = 1
+	c := &appController{}
+	f.seedChildren(c, app)
+	f.seedRolloutChildren(c, app)
+	app.Annotations = map[string]string{promoteAnnotation: "true"}
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	// the stable deployment takes the new image and the canary goes away
+	// the command is consumed before the sync acts on it
+	f.expectPatchAppAction(app)
+	f.expectApplyAction("deployments", app.Namespace, app.Spec.Deployment.Name)
+	f.expectDeleteAction("ingresses", app.Namespace, app.Spec.Ingress.Name+canarySuffix)
+	f.expectDeleteAction("services", app.Namespace, app.Spec.Service.Name+canarySuffix)
+	f.expectDeleteAction("deployments", app.Namespace, app.Spec.Deployment.Name+canarySuffix)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+
+	if rollout := f.updatedStatus().Rollout; rollout.Phase != appcontrollerv1.RolloutHealthy || rollout.StableImage != "nginx:1.1" {
+		t.Errorf("expected nginx:1.1 to be promoted, got %+v", rollout)
+	}
+}
+
+func TestAbortsCanaryRollout(t *testing.T) {
+	f := newFixture(t)
+	app := newRolloutApp("test", "nginx:1.0", "nginx:1.1")
+	c := &appController{}
+	f.seedChildren(c, app)
+	f.seedRolloutChildren(c, app)
+	app.Annotations = map[string]string{abortAnnotation: "true"}
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	// the command is consumed before the sync acts on it
+	f.expectPatchAppAction(app)
+	f.expectDeleteAction("ingresses", app.Namespace, app.Spec.Ingress.Name+canarySuffix)
+	f.expectDeleteAction("services", app.Namespace, app.Spec.Service.Name+canarySuffix)
+	f.expectDeleteAction("deployments", app.Namespace, app.Spec.Deployment.Name+canarySuffix)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+
+	if rollout := f.updatedStatus().Rollout; rollout.Phase != appcontrollerv1.RolloutAborted || rollout.StableImage != "nginx:1.0" {
+		t.Errorf("expected the rollout to be aborted on nginx:1.0, got %+v", rollout)
+	}
+}
+
+func TestPromotesBlueGreenPreview(t *testing.T) {
+	f := newFixture(t)
+	app := newRolloutApp("test", "nginx:1.0", "nginx:1.1")
+	app.Spec.Strategy = appcontrollerv1.StrategyObj{
+		Type:      appcontrollerv1.BlueGreenStrategyType,
+		BlueGreen: &appcontrollerv1.BlueGreenStrategy{AutoPromote: true},
+	}
+	c := &appController{}
+	f.seedChildren(c, app)
+	f.seedRolloutChildren(c, app)
+
+	f.appLister = append(f.appLister, app)
+	f.objects = append(f.objects, app)
+
+	// the service serves the preview while the stable deployment rolls out the new image
+	f.expectApplyAction("deployments", app.Namespace, app.Spec.Deployment.Name)
+	f.expectApplyAction("services", app.Namespace, app.Spec.Service.Name)
+	f.expectApplyAction("deployments", app.Namespace, app.Spec.Deployment.Name+previewSuffix)
+	f.expectApplyAction("services", app.Namespace, app.Spec.Service.Name+previewSuffix)
+	f.expectUpdateAppStatusAction(app)
+
+	f.run(getKey(app, t))
+
+	if rollout := f.updatedStatus().Rollout; rollout.Phase != appcontrollerv1.RolloutPromoting || rollout.StableImage != "nginx:1.1" {
+		t.Errorf("expected the preview to be promoted, got %+v", rollout)
+	}
+	var svc core.Service
+	if err := json.Unmarshal(f.kubeclient.Actions()[1].(core_testing.PatchAction).GetPatch(), &svc); err != nil {
+		t.Fatalf("decode applied service: %v", err)
+	}
+	if selector := svc.Spec.Selector["controller"]; selector != app.Name+previewSuffix {
+		t.Errorf("expected the service to select the preview, got %s", selector)
+	}
+}
@@ -0,0 +1,139 @@
+/*******************************************************************************
+ * @File: apply.go
+ * @Description: server-side apply of App children
+ * @Author: jiangxunyu
+ * @Version: 1.0.0
+ * @Date: 2026/10/18 15:20
+*******************************************************************************/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+
+	deployapps "k8s.io/api/apps/v1"
+	autoscaling "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	net "k8s.io/api/networking/v1"
+	policy "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// DefaultFieldManager is the field manager children are applied with
+const DefaultFieldManager = "app-controller"
+
+// Option configures an appController
+type Option func(*appController)
+
+// WithFieldManager sets the field manager used for server-side apply
+func WithFieldManager(fieldManager string) Option {
+	return func(c *appController) {
+		c.fieldManager = fieldManager
+	}
+}
+
+// WithForceConflicts decides whether apply takes over fields owned by other managers.
+// When disabled, a conflicting apply fails and the app is retried.
+func WithForceConflicts(force bool) Option {
+	return func(c *appController) {
+		c.forceConflicts = force
+	}
+}
+
+func (c *appController) patchOptions() metav1.PatchOptions {
+	force := c.forceConflicts
+	return metav1.PatchOptions{
+		FieldManager: c.fieldManager,
+		Force:        &force,
+	}
+}
+
+// applyConfiguration encodes obj as an apply patch. Status is not part of the intent and is dropped.
+func applyConfiguration(obj runtime.Object) ([]byte, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	delete(fields, "status")
+	return json.Marshal(fields)
+}
+
+func (c *appController) applyDeployment(deploy *deployapps.Deployment) error {
+	data, err := applyConfiguration(deploy)
+	if err != nil {
+		return err
+	}
+	_, err = c.internalClient.AppsV1().Deployments(deploy.Namespace).Patch(context.TODO(), deploy.Name, types.ApplyPatchType, data, c.patchOptions())
+	return err
+}
+
+func (c *appController) applyStatefulSet(sts *deployapps.StatefulSet) error {
+	data, err := applyConfiguration(sts)
+	if err != nil {
+		return err
+	}
+	_, err = c.internalClient.AppsV1().StatefulSets(sts.Namespace).Patch(context.TODO(), sts.Name, types.ApplyPatchType, data, c.patchOptions())
+	return err
+}
+
+func (c *appController) applyJob(job *batchv1.Job) error {
+	data, err := applyConfiguration(job)
+	if err != nil {
+		return err
+	}
+	_, err = c.internalClient.BatchV1().Jobs(job.Namespace).Patch(context.TODO(), job.Name, types.ApplyPatchType, data, c.patchOptions())
+	return err
+}
+
+func (c *appController) applyCronJob(cj *batchv1.CronJob) error {
+	data, err := applyConfiguration(cj)
+	if err != nil {
+		return err
+	}
+	_, err = c.internalClient.BatchV1().CronJobs(cj.Namespace).Patch(context.TODO(), cj.Name, types.ApplyPatchType, data, c.patchOptions())
+	return err
+}
+
+func (c *appController) applyService(svc *core.Service) error {
+	data, err := applyConfiguration(svc)
+	if err != nil {
+		return err
+	}
+	_, err = c.internalClient.CoreV1().Services(svc.Namespace).Patch(context.TODO(), svc.Name, types.ApplyPatchType, data, c.patchOptions())
+	return err
+}
+
+func (c *appController) applyIngress(ingress *net.Ingress) error {
+	data, err := applyConfiguration(ingress)
+	if err != nil {
+		return err
+	}
+	_, err = c.internalClient.NetworkingV1().Ingresses(ingress.Namespace).Patch(context.TODO(), ingress.Name, types.ApplyPatchType, data, c.patchOptions())
+	return err
+}
+
+func (c *appController) applyHorizontalPodAutoscaler(hpa *autoscaling.HorizontalPodAutoscaler) error {
+	data, err := applyConfiguration(hpa)
+	if err != nil {
+		return err
+	}
+	_, err = c.internalClient.AutoscalingV2().HorizontalPodAutoscalers(hpa.Namespace).Patch(context.TODO(), hpa.Name, types.ApplyPatchType, data, c.patchOptions())
+	return err
+}
+
+func (c *appController) applyPodDisruptionBudget(pdb *policy.PodDisruptionBudget) error {
+	data, err := applyConfiguration(pdb)
+	if err != nil {
+		return err
+	}
+	_, err = c.internalClient.PolicyV1().PodDisruptionBudgets(pdb.Namespace).Patch(context.TODO(), pdb.Name, types.ApplyPatchType, data, c.patchOptions())
+	return err
+}
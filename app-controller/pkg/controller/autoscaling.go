@@ -0,0 +1,168 @@
+/*******************************************************************************
+ * @File: autoscaling.go
+ * @Description: HorizontalPodAutoscaler of App deployments
+ * @Author: jiangxunyu
+ * @Version: 1.0.0
+ * @Date: 2026/10/18 23:00
+*******************************************************************************/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	appcontrollerv1 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v1"
+	deployapps "k8s.io/api/apps/v1"
+	autoscaling "k8s.io/api/autoscaling/v2"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// autoscalingEnabled reports whether the workload replicas of app are left to a HorizontalPodAutoscaler
+func autoscalingEnabled(app *appcontrollerv1.App) bool {
+	return app.Spec.Autoscaling.Enabled
+}
+
+// handoverReplicas hands the replicas of workload, a deployment or a statefulset, over to the autoscaler before
+// the controller stops applying them. While the apply of the controller owns the field, leaving it out would reset
+// the workload to 1 replica. The live count is applied once by a separate handover manager, which keeps owning
+// the field until the autoscaler scales.
+func (c *appController) handoverReplicas(workload metav1.Object) error {
+	var kind string
+	var replicas *int32
+	switch w := workload.(type) {
+	case *deployapps.Deployment:
+		kind, replicas = "Deployment", w.Spec.Replicas
+	case *deployapps.StatefulSet:
+		kind, replicas = "StatefulSet", w.Spec.Replicas
+	default:
+		return fmt.Errorf("hand over replicas of %T: not a deployment or statefulset", workload)
+	}
+	if replicas == nil || !appliedField(workload, c.fieldManager, "f:spec", "f:replicas") {
+		return nil
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"apiVersion": deployapps.SchemeGroupVersion.String(),
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": workload.GetName(), "namespace": workload.GetNamespace()},
+		"spec":       map[string]interface{}{"replicas": *replicas},
+	})
+	if err != nil {
+		return err
+	}
+	opts := c.patchOptions()
+	opts.FieldManager = c.fieldManager + "-handover"
+	namespace, name := workload.GetNamespace(), workload.GetName()
+	if kind == "StatefulSet" {
+		_, err = c.internalClient.AppsV1().StatefulSets(namespace).Patch(context.TODO(), name, types.ApplyPatchType, data, opts)
+	} else {
+		_, err = c.internalClient.AppsV1().Deployments(namespace).Patch(context.TODO(), name, types.ApplyPatchType, data, opts)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("hand over %d replicas of %s %s/%s to the autoscaler\n", *replicas, strings.ToLower(kind), namespace, name)
+	return nil
+}
+
+// appliedField reports whether the field at path is owned by an apply of manager in the managed fields of obj
+func appliedField(obj metav1.Object, manager string, path ...string) bool {
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Manager != manager || entry.Operation != metav1.ManagedFieldsOperationApply || entry.FieldsV1 == nil {
+			continue
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		for i, key := range path {
+			value, ok := fields[key]
+			if !ok {
+				break
+			}
+			if i == len(path)-1 {
+				return true
+			}
+			if fields, ok = value.(map[string]interface{}); !ok {
+				break
+			}
+		}
+	}
+	return false
+}
+
+// syncAutoscaler creates, updates or deletes the HorizontalPodAutoscaler of app. It shares the deployment name.
+func (c *appController) syncAutoscaler(app *appcontrollerv1.App) error {
+	name := app.Spec.Deployment.Name
+	return c.syncChild(app, childSync{
+		kind:    "horizontalpodautoscaler",
+		name:    name,
+		enabled: autoscalingEnabled(app),
+		get: func() (metav1.Object, error) {
+			return c.hpaLister.HorizontalPodAutoscalers(app.Namespace).Get(name)
+		},
+		apply: func() error { return c.applyHorizontalPodAutoscaler(c.constructHorizontalPodAutoscaler(app)) },
+		// the workload replicas are applied from the spec again
+		delete: func() error {
+			return c.internalClient.AutoscalingV2().HorizontalPodAutoscalers(app.Namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+		},
+	})
+}
+
+func (c *appController) constructHorizontalPodAutoscaler(app *appcontrollerv1.App) *autoscaling.HorizontalPodAutoscaler {
+	spec := app.Spec.Autoscaling
+	var metrics []autoscaling.MetricSpec
+	if spec.TargetCPUUtilizationPercentage != nil {
+		metrics = append(metrics, resourceUtilizationMetric(core.ResourceCPU, *spec.TargetCPUUtilizationPercentage))
+	}
+	if spec.TargetMemoryUtilizationPercentage != nil {
+		metrics = append(metrics, resourceUtilizationMetric(core.ResourceMemory, *spec.TargetMemoryUtilizationPercentage))
+	}
+	for _, metric := range spec.Metrics {
+		metrics = append(metrics, *metric.DeepCopy())
+	}
+	return &autoscaling.HorizontalPodAutoscaler{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: autoscaling.SchemeGroupVersion.String(),
+			Kind:       "HorizontalPodAutoscaler",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      app.Spec.Deployment.Name,
+			Namespace: app.Namespace,
+			Labels: map[string]string{
+				controllerBy: app.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(app, appcontrollerv1.SchemeGroupVersion.WithKind("App")),
+			},
+		},
+		Spec: autoscaling.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscaling.CrossVersionObjectReference{
+				APIVersion: deployapps.SchemeGroupVersion.String(),
+				Kind:       string(workloadKind(app)),
+				Name:       app.Spec.Deployment.Name,
+			},
+			MinReplicas: spec.MinReplicas,
+			MaxReplicas: spec.MaxReplicas,
+			// no metrics lets the api server default to 80% cpu utilization
+			Metrics: metrics,
+		},
+	}
+}
+
+func resourceUtilizationMetric(name core.ResourceName, percentage int32) autoscaling.MetricSpec {
+	return autoscaling.MetricSpec{
+		Type: autoscaling.ResourceMetricSourceType,
+		Resource: &autoscaling.ResourceMetricSource{
+			Name: name,
+			Target: autoscaling.MetricTarget{
+				Type:               autoscaling.UtilizationMetricType,
+				AverageUtilization: &percentage,
+			},
+		},
+	}
+}
@@ -0,0 +1,330 @@
+/*******************************************************************************
+ * @File: batch.go
+ * @Description: Job and CronJob workloads of Apps
+ * @Author: jiangxunyu
+ * @Version: 1.0.0
+ * @Date: 2026/10/21 09:40
+*******************************************************************************/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appcontrollerv1 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// isBatchWorkload reports whether the pods of app run to completion instead of being kept running
+func isBatchWorkload(app *appcontrollerv1.App) bool {
+	kind := workloadKind(app)
+	return kind == appcontrollerv1.JobWorkloadKind || kind == appcontrollerv1.CronJobWorkloadKind
+}
+
+// syncJob creates the job of app, or runs it again when its spec changed
+func (c *appController) syncJob(app *appcontrollerv1.App) error {
+	job, err := c.jobLister.Jobs(app.Namespace).Get(app.Spec.Deployment.Name)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if errors.IsNotFound(err) {
+		// create job
+		if err := c.applyJob(c.constructJob(app)); err != nil {
+			return err
+		}
+		fmt.Println("create job success")
+		c.recorder.Eventf(app, core.EventTypeNormal, EventReasonCreated, "Created job %s", app.Spec.Deployment.Name)
+		return nil
+	}
+	// a drifted job is deleted here and created again by the sync following its delete event
+	_, err = c.repairJob(app, job)
+	return err
+}
+
+// repairJob deletes job when a field owned by app drifted, the pod template of a job is immutable
+func (c *appController) repairJob(app *appcontrollerv1.App, job *batchv1.Job) ([]string, error) {
+	if !metav1.IsControlledBy(job, app) {
+		return nil, fmt.Errorf("job %s/%s already exists and is not managed by app %s", job.Namespace, job.Name, app.Name)
+	}
+	drift := jobDrift(c.constructJob(app), job)
+	if len(drift) == 0 {
+		return nil, nil
+	}
+	// jobs orphan their pods by default, the pods of the previous run go with it
+	uid := job.UID
+	propagation := metav1.DeletePropagationBackground
+	err := c.internalClient.BatchV1().Jobs(job.Namespace).Delete(context.TODO(), job.Name, metav1.DeleteOptions{
+		Preconditions:     &metav1.Preconditions{UID: &uid},
+		PropagationPolicy: &propagation,
+	})
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	fmt.Printf("delete job %s/%s to run it again, drift: %s\n", job.Namespace, job.Name, strings.Join(drift, ", "))
+	c.recorder.Eventf(app, core.EventTypeNormal, EventReasonDeleted, "Deleted job %s to run it again with a new %s",
+		job.Name, strings.Join(drift, ", "))
+	return drift, nil
+}
+
+// jobDrift compares the fields owned by the controller in desired and live
+func jobDrift(desired, live *batchv1.Job) []string {
+	var drift []string
+	if labelsDrifted(live.Labels, desired.Labels) {
+		drift = append(drift, "metadata.labels")
+	}
+	if desired.Spec.Completions != nil && (live.Spec.Completions == nil || *live.Spec.Completions != *desired.Spec.Completions) {
+		drift = append(drift, "spec.completions")
+	}
+	if desired.Spec.BackoffLimit != nil && (live.Spec.BackoffLimit == nil || *live.Spec.BackoffLimit != *desired.Spec.BackoffLimit) {
+		drift = append(drift, "spec.backoffLimit")
+	}
+	return append(drift, podTemplateDrift(desired.Spec.Template, live.Spec.Template)...)
+}
+
+// syncCronJob creates the cronjob of app or repairs its drift, the jobs it schedules are left to the cronjob controller
+func (c *appController) syncCronJob(app *appcontrollerv1.App) error {
+	cj, err := c.cronJobLister.CronJobs(app.Namespace).Get(app.Spec.Deployment.Name)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if errors.IsNotFound(err) {
+		// create cronjob
+		if err := c.applyCronJob(c.constructCronJob(app)); err != nil {
+			return err
+		}
+		fmt.Println("create cronjob success")
+		c.recorder.Eventf(app, core.EventTypeNormal, EventReasonCreated, "Created cronjob %s", app.Spec.Deployment.Name)
+		return nil
+	}
+	// repair any drift on the fields owned by the app
+	_, err = c.repairCronJob(app, cj)
+	return err
+}
+
+// repairCronJob brings the fields of cj owned by app back to the desired state and returns the drifted fields
+func (c *appController) repairCronJob(app *appcontrollerv1.App, cj *batchv1.CronJob) ([]string, error) {
+	if !metav1.IsControlledBy(cj, app) {
+		return nil, fmt.Errorf("cronjob %s/%s already exists and is not managed by app %s", cj.Namespace, cj.Name, app.Name)
+	}
+	desired := c.constructCronJob(app)
+	drift := cronJobDrift(desired, cj)
+	if len(drift) == 0 && !specChanged(app) {
+		return nil, nil
+	}
+	if err := c.applyCronJob(desired); err != nil {
+		return nil, err
+	}
+	if len(drift) > 0 {
+		fmt.Printf("repair cronjob %s/%s drift: %s\n", cj.Namespace, cj.Name, strings.Join(drift, ", "))
+		c.recorder.Eventf(app, core.EventTypeNormal, EventReasonUpdated, "Repaired drift of cronjob %s: %s", cj.Name, strings.Join(drift, ", "))
+	} else {
+		c.recorder.Eventf(app, core.EventTypeNormal, EventReasonUpdated, "Updated cronjob %s", cj.Name)
+	}
+	return drift, nil
+}
+
+// cronJobDrift compares the fields owned by the controller in desired and live, the same way as deploymentDrift
+func cronJobDrift(desired, live *batchv1.CronJob) []string {
+	var drift []string
+	if labelsDrifted(live.Labels, desired.Labels) {
+		drift = append(drift, "metadata.labels")
+	}
+	if live.Spec.Schedule != desired.Spec.Schedule {
+		drift = append(drift, "spec.schedule")
+	}
+	if desired.Spec.ConcurrencyPolicy != "" && live.Spec.ConcurrencyPolicy != desired.Spec.ConcurrencyPolicy {
+		drift = append(drift, "spec.concurrencyPolicy")
+	}
+	for _, field := range podTemplateDrift(desired.Spec.JobTemplate.Spec.Template, live.Spec.JobTemplate.Spec.Template) {
+		drift = append(drift, "spec.jobTemplate."+field)
+	}
+	return drift
+}
+
+// constructJobSpec builds the spec shared by the job and the job template of the cronjob of app
+func (c *appController) constructJobSpec(app *appcontrollerv1.App) batchv1.JobSpec {
+	labels := map[string]string{
+		"app":        app.Name,
+		"controller": selectorName(app),
+	}
+	parallelism := app.Spec.Deployment.Replicas
+	completions := app.Spec.Deployment.Replicas
+	template := c.constructPodTemplate(app, labels)
+	// a failed pod is replaced by a new one counting against the backoff limit
+	template.Spec.RestartPolicy = core.RestartPolicyNever
+	spec := batchv1.JobSpec{
+		Parallelism: &parallelism,
+		Completions: &completions,
+		Template:    template,
+	}
+	if batch := app.Spec.Deployment.Batch; batch != nil {
+		spec.BackoffLimit = batch.BackoffLimit
+	}
+	return spec
+}
+
+func (c *appController) constructJob(app *appcontrollerv1.App) *batchv1.Job {
+	return &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: batchv1.SchemeGroupVersion.String(),
+			Kind:       "Job",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      app.Spec.Deployment.Name,
+			Namespace: app.Namespace,
+			Labels: map[string]string{
+				controllerBy: app.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(app, appcontrollerv1.SchemeGroupVersion.WithKind("App")),
+			},
+		},
+		// the selector is generated by the api server from the uid of the job
+		Spec: c.constructJobSpec(app),
+	}
+}
+
+func (c *appController) constructCronJob(app *appcontrollerv1.App) *batchv1.CronJob {
+	cj := &batchv1.CronJob{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: batchv1.SchemeGroupVersion.String(),
+			Kind:       "CronJob",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      app.Spec.Deployment.Name,
+			Namespace: app.Namespace,
+			Labels: map[string]string{
+				controllerBy: app.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(app, appcontrollerv1.SchemeGroupVersion.WithKind("App")),
+			},
+		},
+		Spec: batchv1.CronJobSpec{
+			JobTemplate: batchv1.JobTemplateSpec{
+				// the scheduled jobs are listed by these labels to report their failures
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app":        app.Name,
+						"controller": selectorName(app),
+					},
+				},
+				Spec: c.constructJobSpec(app),
+			},
+		},
+	}
+	if batch := app.Spec.Deployment.Batch; batch != nil {
+		cj.Spec.Schedule = batch.Schedule
+		cj.Spec.ConcurrencyPolicy = batch.ConcurrencyPolicy
+		cj.Spec.SuccessfulJobsHistoryLimit = batch.SuccessfulJobsHistoryLimit
+		cj.Spec.FailedJobsHistoryLimit = batch.FailedJobsHistoryLimit
+	}
+	return cj
+}
+
+// jobStatus reports the job of app in status and returns whether it completed
+func (c *appController) jobStatus(app *appcontrollerv1.App, status *appcontrollerv1.AppStatus) (bool, error) {
+	job, err := c.jobLister.Jobs(app.Namespace).Get(app.Spec.Deployment.Name)
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	if errors.IsNotFound(err) {
+		c.setCondition(app, status, appcontrollerv1.AppDeploymentAvailable, metav1.ConditionFalse, reasonNotFound,
+			fmt.Sprintf("job %s not found", app.Spec.Deployment.Name))
+		return false, nil
+	}
+	// the pods of a job run to completion, the running ones are its replicas
+	status.Replicas = job.Status.Active
+	status.ReadyReplicas = job.Status.Active
+	if failed := jobCondition(job, batchv1.JobFailed); failed != nil {
+		status.LastFailureTime = laterTime(status.LastFailureTime, &failed.LastTransitionTime)
+		c.setCondition(app, status, appcontrollerv1.AppDeploymentAvailable, metav1.ConditionFalse, reasonFailed,
+			fmt.Sprintf("job %s failed: %s", job.Name, failed.Message))
+		return false, nil
+	}
+	if jobCondition(job, batchv1.JobComplete) == nil {
+		c.setCondition(app, status, appcontrollerv1.AppDeploymentAvailable, metav1.ConditionFalse, reasonRunning,
+			fmt.Sprintf("%d/%d completions, %d pods active", job.Status.Succeeded, app.Spec.Deployment.Replicas, job.Status.Active))
+		return false, nil
+	}
+	status.LastSuccessfulTime = laterTime(status.LastSuccessfulTime, job.Status.CompletionTime)
+	c.setCondition(app, status, appcontrollerv1.AppDeploymentAvailable, metav1.ConditionTrue, reasonComplete,
+		fmt.Sprintf("job %s completed", job.Name))
+	return true, nil
+}
+
+// cronJobStatus reports the cronjob of app in status and returns whether its last job did not fail
+func (c *appController) cronJobStatus(app *appcontrollerv1.App, status *appcontrollerv1.AppStatus) (bool, error) {
+	cj, err := c.cronJobLister.CronJobs(app.Namespace).Get(app.Spec.Deployment.Name)
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	if errors.IsNotFound(err) {
+		c.setCondition(app, status, appcontrollerv1.AppDeploymentAvailable, metav1.ConditionFalse, reasonNotFound,
+			fmt.Sprintf("cronjob %s not found", app.Spec.Deployment.Name))
+		return false, nil
+	}
+	status.LastScheduleTime = laterTime(status.LastScheduleTime, cj.Status.LastScheduleTime)
+	status.LastSuccessfulTime = laterTime(status.LastSuccessfulTime, cj.Status.LastSuccessfulTime)
+
+	// the cronjob only records successes, failures are read from the jobs kept in its history
+	jobs, err := c.jobLister.Jobs(app.Namespace).List(labels.SelectorFromSet(cj.Spec.JobTemplate.Labels))
+	if err != nil {
+		return false, err
+	}
+	for _, job := range jobs {
+		if !metav1.IsControlledBy(job, cj) {
+			continue
+		}
+		status.Replicas += job.Status.Active
+		status.ReadyReplicas += job.Status.Active
+		if failed := jobCondition(job, batchv1.JobFailed); failed != nil {
+			status.LastFailureTime = laterTime(status.LastFailureTime, &failed.LastTransitionTime)
+		}
+	}
+
+	switch {
+	case status.LastFailureTime != nil && (status.LastSuccessfulTime == nil || status.LastSuccessfulTime.Before(status.LastFailureTime)):
+		c.setCondition(app, status, appcontrollerv1.AppDeploymentAvailable, metav1.ConditionFalse, reasonFailed,
+			fmt.Sprintf("last job of cronjob %s failed at %s", cj.Name, status.LastFailureTime.UTC().Format(timeFormat)))
+		return false, nil
+	case status.LastSuccessfulTime != nil:
+		c.setCondition(app, status, appcontrollerv1.AppDeploymentAvailable, metav1.ConditionTrue, reasonComplete,
+			fmt.Sprintf("last job of cronjob %s succeeded at %s", cj.Name, status.LastSuccessfulTime.UTC().Format(timeFormat)))
+	default:
+		c.setCondition(app, status, appcontrollerv1.AppDeploymentAvailable, metav1.ConditionTrue, reasonScheduled,
+			fmt.Sprintf("cronjob %s scheduled %q", cj.Name, cj.Spec.Schedule))
+	}
+	return true, nil
+}
+
+// timeFormat formats the job times in condition messages
+const timeFormat = "2006-01-02T15:04:05Z"
+
+// jobCondition returns the condition of job of type conditionType if it is true, nil otherwise
+func jobCondition(job *batchv1.Job, conditionType batchv1.JobConditionType) *batchv1.JobCondition {
+	for i := range job.Status.Conditions {
+		cond := &job.Status.Conditions[i]
+		if cond.Type == conditionType && cond.Status == core.ConditionTrue {
+			return cond
+		}
+	}
+	return nil
+}
+
+// laterTime returns the later of the recorded and the observed time, so that pruned jobs do not erase the history in status
+func laterTime(recorded, observed *metav1.Time) *metav1.Time {
+	if observed == nil || observed.IsZero() {
+		return recorded
+	}
+	if recorded == nil || recorded.Before(observed) {
+		return observed.DeepCopy()
+	}
+	return recorded
+}
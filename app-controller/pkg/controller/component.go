@@ -0,0 +1,106 @@
+/*******************************************************************************
+ * @File: component.go
+ * @Description: reconcile the components after the first one of a v2 App
+ * @Author: jiangxunyu
+ * @Version: 1.0.0
+ * @Date: 2026/10/22 09:40
+*******************************************************************************/
+
+package controller
+
+import (
+	appcontrollerv1 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v1"
+	appcontrollerv2 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v2"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+// componentAnnotation names the component an app returned by componentApps stands for
+const componentAnnotation = "appcontroller.me/component"
+
+// componentApps returns the components after the first one of an app written as v2, each as an app of its own
+// whose children are synced like those of app
+func componentApps(app *appcontrollerv1.App) ([]*appcontrollerv1.App, error) {
+	extra, err := appcontrollerv2.ExtraComponents(app)
+	if err != nil {
+		return nil, err
+	}
+	apps := make([]*appcontrollerv1.App, 0, len(extra))
+	for _, component := range extra {
+		componentApp := appcontrollerv2.ComponentApp(app, component)
+		if componentApp.Annotations == nil {
+			componentApp.Annotations = map[string]string{}
+		}
+		componentApp.Annotations[componentAnnotation] = component.Name
+		appcontrollerv1.SetDefaults_App(componentApp)
+		apps = append(apps, componentApp)
+	}
+	return apps, nil
+}
+
+// selectorName is the controller label of the pods of app, the pods of each component are selected apart
+func selectorName(app *appcontrollerv1.App) string {
+	if component, ok := app.Annotations[componentAnnotation]; ok {
+		return app.Name + "-" + component
+	}
+	return app.Name
+}
+
+// syncComponents syncs the children of every component after the first one
+func (c *appController) syncComponents(app *appcontrollerv1.App) error {
+	components, err := componentApps(app)
+	if err != nil {
+		return err
+	}
+	for _, component := range components {
+		if err := c.syncWorkload(component); err != nil {
+			return err
+		}
+		if err := c.syncService(component); err != nil {
+			return err
+		}
+		if err := c.syncIngress(component); err != nil {
+			return err
+		}
+		if err := c.syncAutoscaler(component); err != nil {
+			return err
+		}
+		if err := c.syncDisruptionBudget(component); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// componentsStatus reports the components after the first one in status.
+// It returns whether they are all ready and the drift of their workloads while the app is paused.
+func (c *appController) componentsStatus(app *appcontrollerv1.App, status *appcontrollerv1.AppStatus, syncErr error) (bool, []string, error) {
+	status.Components = nil
+	components, err := componentApps(app)
+	if err != nil {
+		// reported by the InvalidSpec reason of the Ready condition
+		return true, nil, nil
+	}
+	ready := true
+	var drift []string
+	for _, component := range components {
+		componentStatus, err := c.computeAppStatus(component, syncErr)
+		if err != nil {
+			return false, nil, err
+		}
+		status.Components = append(status.Components, appcontrollerv1.ComponentStatus{
+			Name:               component.Annotations[componentAnnotation],
+			Replicas:           componentStatus.Replicas,
+			ReadyReplicas:      componentStatus.ReadyReplicas,
+			WorkloadName:       componentStatus.DeploymentName,
+			ServiceName:        componentStatus.ServiceName,
+			IngressName:        componentStatus.IngressName,
+			Selector:           componentStatus.Selector,
+			LastScheduleTime:   componentStatus.LastScheduleTime,
+			LastSuccessfulTime: componentStatus.LastSuccessfulTime,
+			LastFailureTime:    componentStatus.LastFailureTime,
+		})
+		drift = append(drift, componentStatus.Drift...)
+		ready = ready && meta.IsStatusConditionTrue(componentStatus.Conditions, appcontrollerv1.AppReady)
+	}
+	return ready, drift, nil
+}
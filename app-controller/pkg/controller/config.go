@@ -0,0 +1,131 @@
+/*******************************************************************************
+ * @File: config.go
+ * @Description: roll out App deployments when referenced configmaps or secrets change
+ * @Author: jiangxunyu
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 09:30
+*******************************************************************************/
+
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+
+	appcontrollerv1 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// configHashAnnotation on the pod template changes with the content of the referenced configmaps and secrets,
+// so that any change rolls out new pods
+const configHashAnnotation = "appcontroller.me/config-hash"
+
+// configHash returns a digest of the configmaps and secrets referenced by app, empty when it references none.
+// A missing object hashes like an empty one, its creation rolls out the deployment.
+func (c *appController) configHash(app *appcontrollerv1.App) string {
+	if len(app.Spec.Deployment.ConfigMaps) == 0 && len(app.Spec.Deployment.Secrets) == 0 {
+		return ""
+	}
+	hash := sha256.New()
+	for _, name := range sortedNames(app.Spec.Deployment.ConfigMaps) {
+		fmt.Fprintf(hash, "configmap/%s\n", name)
+		cm, err := c.configMapLister.ConfigMaps(app.Namespace).Get(name)
+		if err != nil {
+			continue
+		}
+		data := make(map[string][]byte, len(cm.Data)+len(cm.BinaryData))
+		for k, v := range cm.Data {
+			data[k] = []byte(v)
+		}
+		for k, v := range cm.BinaryData {
+			data[k] = v
+		}
+		writeData(hash, data)
+	}
+	for _, name := range sortedNames(app.Spec.Deployment.Secrets) {
+		fmt.Fprintf(hash, "secret/%s\n", name)
+		secret, err := c.secretLister.Secrets(app.Namespace).Get(name)
+		if err != nil {
+			continue
+		}
+		writeData(hash, secret.Data)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// writeData writes the entries of data to w in key order
+func writeData(w io.Writer, data map[string][]byte) {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s=%d:%s\n", k, len(data[k]), data[k])
+	}
+}
+
+func sortedNames(names []string) []string {
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+func (c *appController) configMapEvent(obj interface{}) {
+	c.enqueueReferencingApps(obj, func(app *appcontrollerv1.App) []string { return app.Spec.Deployment.ConfigMaps })
+}
+
+func (c *appController) secretEvent(obj interface{}) {
+	c.enqueueReferencingApps(obj, func(app *appcontrollerv1.App) []string { return app.Spec.Deployment.Secrets })
+}
+
+func (c *appController) updateConfigEvent(handler func(obj interface{})) func(oldObj, newObj interface{}) {
+	return func(oldObj, newObj interface{}) {
+		oldMeta, err := meta.Accessor(oldObj)
+		if err != nil {
+			return
+		}
+		newMeta, err := meta.Accessor(newObj)
+		if err != nil {
+			return
+		}
+		// periodic resync sends the same object again
+		if oldMeta.GetResourceVersion() == newMeta.GetResourceVersion() {
+			return
+		}
+		handler(newObj)
+	}
+}
+
+// enqueueReferencingApps enqueues every app in the namespace of obj whose refs name it. obj may be a deletion tombstone.
+func (c *appController) enqueueReferencingApps(obj interface{}, refs func(app *appcontrollerv1.App) []string) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	object, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	apps, err := c.appLister.Apps(object.GetNamespace()).List(labels.Everything())
+	if err != nil {
+		return
+	}
+	for _, app := range apps {
+		// an invalid components annotation is reported by the sync of app
+		components, _ := componentApps(app)
+	refs:
+		for _, referencing := range append([]*appcontrollerv1.App{app}, components...) {
+			for _, name := range refs(referencing) {
+				if name == object.GetName() {
+					c.enqueue(app.Namespace + "/" + app.Name)
+					break refs
+				}
+			}
+		}
+	}
+}
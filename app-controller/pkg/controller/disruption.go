@@ -0,0 +1,69 @@
+/*******************************************************************************
+ * @File: disruption.go
+ * @Description: PodDisruptionBudget of App deployments
+ * @Author: jiangxunyu
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 14:30
+*******************************************************************************/
+
+package controller
+
+import (
+	"context"
+
+	appcontrollerv1 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v1"
+	policy "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// syncDisruptionBudget creates, updates or deletes the PodDisruptionBudget of app. It shares the deployment name.
+func (c *appController) syncDisruptionBudget(app *appcontrollerv1.App) error {
+	name := app.Spec.Deployment.Name
+	return c.syncChild(app, childSync{
+		kind:    "poddisruptionbudget",
+		name:    name,
+		enabled: app.Spec.DisruptionBudget.Enabled,
+		get:     func() (metav1.Object, error) { return c.pdbLister.PodDisruptionBudgets(app.Namespace).Get(name) },
+		apply:   func() error { return c.applyPodDisruptionBudget(c.constructPodDisruptionBudget(app)) },
+		delete: func() error {
+			return c.internalClient.PolicyV1().PodDisruptionBudgets(app.Namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+		},
+	})
+}
+
+func (c *appController) constructPodDisruptionBudget(app *appcontrollerv1.App) *policy.PodDisruptionBudget {
+	spec := app.Spec.DisruptionBudget
+	minAvailable, maxUnavailable := spec.MinAvailable, spec.MaxUnavailable
+	if minAvailable == nil && maxUnavailable == nil {
+		one := intstr.FromInt(1)
+		minAvailable = &one
+	}
+	return &policy.PodDisruptionBudget{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: policy.SchemeGroupVersion.String(),
+			Kind:       "PodDisruptionBudget",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      app.Spec.Deployment.Name,
+			Namespace: app.Namespace,
+			Labels: map[string]string{
+				controllerBy: app.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(app, appcontrollerv1.SchemeGroupVersion.WithKind("App")),
+			},
+		},
+		Spec: policy.PodDisruptionBudgetSpec{
+			// the selector of the deployment, rollout pods are not covered
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app":        app.Name,
+					"controller": selectorName(app),
+				},
+			},
+			MinAvailable:   minAvailable,
+			MaxUnavailable: maxUnavailable,
+		},
+	}
+}
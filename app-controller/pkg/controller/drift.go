@@ -0,0 +1,209 @@
+/*******************************************************************************
+ * @File: drift.go
+ * @Description: detect and repair drift of App managed workloads
+ * @Author: jiangxunyu
+ * @Version: 1.0.0
+ * @Date: 2026/10/18 13:40
+*******************************************************************************/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	appcontrollerv1 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v1"
+	deployapps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// repairDeployment brings the fields of deploy owned by app back to the desired state and returns the drifted fields
+func (c *appController) repairDeployment(app *appcontrollerv1.App, deploy *deployapps.Deployment) ([]string, error) {
+	if !metav1.IsControlledBy(deploy, app) {
+		return nil, fmt.Errorf("deployment %s/%s already exists and is not managed by app %s", deploy.Namespace, deploy.Name, app.Name)
+	}
+	desired := c.constructDeployment(app, app.Spec.Deployment.Replicas)
+
+	// the selector is immutable, a deployment selecting other pods has to be recreated
+	if !equality.Semantic.DeepEqual(desired.Spec.Selector, deploy.Spec.Selector) {
+		uid := deploy.UID
+		err := c.internalClient.AppsV1().Deployments(deploy.Namespace).Delete(context.TODO(), deploy.Name, metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{UID: &uid},
+		})
+		if err != nil {
+			return nil, err
+		}
+		c.recorder.Eventf(app, core.EventTypeNormal, EventReasonDeleted, "Deleted deployment %s to recreate it with a new selector", deploy.Name)
+		return []string{"spec.selector (recreate)"}, nil
+	}
+
+	drift := deploymentDrift(desired, deploy)
+	if len(drift) == 0 && !specChanged(app) {
+		return nil, nil
+	}
+	if autoscalingEnabled(app) {
+		if err := c.handoverReplicas(deploy); err != nil {
+			return nil, err
+		}
+	}
+	// applying the full desired state takes back every field owned by the controller
+	if err := c.applyDeployment(desired); err != nil {
+		return nil, err
+	}
+	if len(drift) > 0 {
+		fmt.Printf("repair deployment %s/%s drift: %s\n", deploy.Namespace, deploy.Name, strings.Join(drift, ", "))
+		c.recorder.Eventf(app, core.EventTypeNormal, EventReasonUpdated, "Repaired drift of deployment %s: %s", deploy.Name, strings.Join(drift, ", "))
+	} else {
+		c.recorder.Eventf(app, core.EventTypeNormal, EventReasonUpdated, "Updated deployment %s", deploy.Name)
+	}
+	return drift, nil
+}
+
+// deploymentDrift compares the fields owned by the controller in desired and live
+func deploymentDrift(desired, live *deployapps.Deployment) []string {
+	var drift []string
+	if labelsDrifted(live.Labels, desired.Labels) {
+		drift = append(drift, "metadata.labels")
+	}
+	if desired.Spec.Replicas != nil && (live.Spec.Replicas == nil || *live.Spec.Replicas != *desired.Spec.Replicas) {
+		drift = append(drift, "spec.replicas")
+	}
+	return append(drift, podTemplateDrift(desired.Spec.Template, live.Spec.Template)...)
+}
+
+// podTemplateDrift compares the fields of the pod template owned by the controller in desired and live
+func podTemplateDrift(desired, live core.PodTemplateSpec) []string {
+	var drift []string
+	if labelsDrifted(live.Labels, desired.Labels) {
+		drift = append(drift, "spec.template.metadata.labels")
+	}
+	if labelsDrifted(live.Annotations, desired.Annotations) {
+		drift = append(drift, "spec.template.metadata.annotations")
+	}
+	drift = append(drift, containersDrift(desired.Spec.Containers, live.Spec.Containers)...)
+
+	// the pod spec fields next to the containers, e.g. the volumes and the restart policy
+	want, got := desired.Spec.DeepCopy(), live.Spec.DeepCopy()
+	want.Containers, got.Containers = nil, nil
+	for _, field := range ownedFieldsDrift(want, got) {
+		drift = append(drift, "spec.template.spec."+field)
+	}
+	return drift
+}
+
+// labelsDrifted reports whether any desired label or annotation is missing or changed in live
+func labelsDrifted(live, desired map[string]string) bool {
+	for k, v := range desired {
+		if cur, ok := live[k]; !ok || cur != v {
+			return true
+		}
+	}
+	return false
+}
+
+// containersDrift compares the fields set on each desired container with the live container of the same name
+func containersDrift(desired, live []core.Container) []string {
+	var drift []string
+	liveByName := make(map[string]core.Container, len(live))
+	for _, container := range live {
+		liveByName[container.Name] = container
+	}
+	for i := range desired {
+		want := &desired[i]
+		got, ok := liveByName[want.Name]
+		if !ok {
+			drift = append(drift, fmt.Sprintf("spec.template.spec.containers[%s] (missing)", want.Name))
+			continue
+		}
+		for _, field := range ownedFieldsDrift(want, &got) {
+			drift = append(drift, fmt.Sprintf("spec.template.spec.containers[%s].%s", want.Name, field))
+		}
+	}
+	return drift
+}
+
+// ownedFieldsDrift returns the top level fields set in desired whose value is missing or changed in live
+func ownedFieldsDrift(desired, live interface{}) []string {
+	want, err := runtime.DefaultUnstructuredConverter.ToUnstructured(desired)
+	if err != nil {
+		return nil
+	}
+	got, err := runtime.DefaultUnstructuredConverter.ToUnstructured(live)
+	if err != nil {
+		return nil
+	}
+	var drift []string
+	for field, value := range want {
+		if !ownedValueMatches(value, got[field]) {
+			drift = append(drift, field)
+		}
+	}
+	sort.Strings(drift)
+	return drift
+}
+
+// ownedValueMatches reports whether live holds the value applied as want, lists of objects match item by item
+func ownedValueMatches(want, live interface{}) bool {
+	switch want := want.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		// an empty object is owned as well, e.g. the emptyDir source of a volume
+		liveFields, ok := live.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for field, value := range want {
+			if !ownedValueMatches(value, liveFields[field]) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		liveItems, _ := live.([]interface{})
+		if len(want) == 0 || !isObjectList(want) {
+			return len(want) == 0 && len(liveItems) == 0 || equality.Semantic.DeepEqual(want, liveItems)
+		}
+		for _, item := range want {
+			found := false
+			for _, liveItem := range liveItems {
+				if ownedValueMatches(item, liveItem) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+	return equality.Semantic.DeepEqual(want, live)
+}
+
+// isObjectList reports whether items are objects, which are merged by key by server-side apply instead of replaced
+func isObjectList(items []interface{}) bool {
+	for _, item := range items {
+		if _, ok := item.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+/*******************************************************************************
+ * @File: events.go
+ * @Description: kubernetes events recorded on App objects
+ * @Author: jiangxunyu
+ * @Version: 1.0.0
+ * @Date: 2026/10/18 19:10
+*******************************************************************************/
+
+package controller
+
+import (
+	appscheme "github.com/istudies/k8s-operator/app-controller/pkg/generated/clientset/versioned/scheme"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// event reasons, shown by `kubectl describe app`
+const (
+	EventReasonCreated     = "Created"
+	EventReasonUpdated     = "Updated"
+	EventReasonDeleted     = "Deleted"
+	EventReasonOrphaned    = "Orphaned"
+	EventReasonSyncFailed  = "SyncFailed"
+	EventReasonInvalidSpec = "InvalidSpec"
+	EventReasonPaused      = "Paused"
+	EventReasonResumed     = "Resumed"
+
+	EventReasonRolloutStarted   = "RolloutStarted"
+	EventReasonPromoted         = "Promoted"
+	EventReasonAborted          = "Aborted"
+	EventReasonRolloutCompleted = "RolloutCompleted"
+)
+
+// WithEventRecorder replaces the recorder writing events to the api server, e.g. with a record.FakeRecorder
+func WithEventRecorder(recorder record.EventRecorder) Option {
+	return func(c *appController) {
+		c.recorder = recorder
+	}
+}
+
+// newEventRecorder returns a recorder sending events on Apps to the api server through client
+func newEventRecorder(client kubernetes.Interface) record.EventRecorder {
+	// the recorder resolves object references through the scheme, which has to know about App
+	runtime.Must(appscheme.AddToScheme(scheme.Scheme))
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme.Scheme, core.EventSource{Component: "app-controller"})
+}
@@ -0,0 +1,312 @@
+/*******************************************************************************
+ * @File: finalizer.go
+ * @Description: finalizer based teardown of App children
+ * @Author: jiangxunyu
+ * @Version: 1.0.0
+ * @Date: 2026/10/18 11:05
+*******************************************************************************/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	appcontrollerv1 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// appFinalizer blocks the removal of an App until its children are deleted or orphaned
+const appFinalizer = "appcontroller.me/finalizer"
+
+func hasFinalizer(obj metav1.Object, finalizer string) bool {
+	for _, f := range obj.GetFinalizers() {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// ensureFinalizer adds appFinalizer to app and returns the updated object
+func (c *appController) ensureFinalizer(app *appcontrollerv1.App) (*appcontrollerv1.App, error) {
+	if hasFinalizer(app, appFinalizer) {
+		return app, nil
+	}
+	newApp := app.DeepCopy()
+	newApp.Finalizers = append(newApp.Finalizers, appFinalizer)
+	return c.appClient.AppcontrollerV1().Apps(app.Namespace).Update(context.TODO(), newApp, metav1.UpdateOptions{})
+}
+
+// removeFinalizer releases appFinalizer so the api server can remove app
+func (c *appController) removeFinalizer(app *appcontrollerv1.App) error {
+	newApp := app.DeepCopy()
+	finalizers := make([]string, 0, len(newApp.Finalizers))
+	for _, f := range newApp.Finalizers {
+		if f != appFinalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	newApp.Finalizers = finalizers
+	_, err := c.appClient.AppcontrollerV1().Apps(app.Namespace).Update(context.TODO(), newApp, metav1.UpdateOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// deletionPolicy returns the policy applied to the children of a deleting app.
+// `kubectl delete --cascade=orphan` is honored the same way as an explicit Orphan policy.
+func deletionPolicy(app *appcontrollerv1.App) appcontrollerv1.DeletionPolicy {
+	if hasFinalizer(app, metav1.FinalizerOrphanDependents) {
+		return appcontrollerv1.DeletionPolicyOrphan
+	}
+	if app.Spec.DeletionPolicy == "" {
+		return appcontrollerv1.DeletionPolicyDelete
+	}
+	return app.Spec.DeletionPolicy
+}
+
+// finalizeApp deletes or orphans every child controlled by app, and releases the finalizer once none is left.
+// Children are handled in a fixed order: ingresses, services, autoscalers, then the workloads.
+func (c *appController) finalizeApp(app *appcontrollerv1.App) error {
+	if !hasFinalizer(app, appFinalizer) {
+		return nil
+	}
+	children, err := c.listOwnedChildren(app)
+	if err != nil {
+		return err
+	}
+	if len(children) == 0 {
+		if err := c.removeFinalizer(app); err != nil {
+			return err
+		}
+		fmt.Printf("release finalizer of app %s/%s\n", app.Namespace, app.Name)
+		return nil
+	}
+
+	policy := deletionPolicy(app)
+	var errs []error
+	for _, child := range children {
+		var err error
+		if policy == appcontrollerv1.DeletionPolicyOrphan {
+			err = c.orphanChild(app, child)
+		} else {
+			err = c.deleteChild(app, child)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s/%s: %v", child.kind, child.obj.GetNamespace(), child.obj.GetName(), err))
+		}
+	}
+	if len(errs) > 0 {
+		return utilerrors.NewAggregate(errs)
+	}
+	// orphaned children are gone from the owned set as soon as the patch succeeds,
+	// deleted ones are observed through their delete events which requeue the app
+	if policy == appcontrollerv1.DeletionPolicyOrphan {
+		return c.removeFinalizer(app)
+	}
+	return nil
+}
+
+// ownedChild is a child object together with the resource it belongs to
+type ownedChild struct {
+	kind string
+	obj  metav1.Object
+}
+
+// listOwnedChildren returns children labeled with and controlled by app, read from the informer caches
+func (c *appController) listOwnedChildren(app *appcontrollerv1.App) ([]ownedChild, error) {
+	selector := labels.SelectorFromSet(labels.Set{controllerBy: app.Name})
+	var children []ownedChild
+
+	ingresses, err := c.ingressLister.Ingresses(app.Namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range ingresses {
+		if metav1.IsControlledBy(item, app) {
+			children = append(children, ownedChild{kind: "ingress", obj: item})
+		}
+	}
+
+	services, err := c.serviceLister.Services(app.Namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range services {
+		if metav1.IsControlledBy(item, app) {
+			children = append(children, ownedChild{kind: "service", obj: item})
+		}
+	}
+
+	hpas, err := c.hpaLister.HorizontalPodAutoscalers(app.Namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range hpas {
+		if metav1.IsControlledBy(item, app) {
+			children = append(children, ownedChild{kind: "horizontalpodautoscaler", obj: item})
+		}
+	}
+
+	pdbs, err := c.pdbLister.PodDisruptionBudgets(app.Namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range pdbs {
+		if metav1.IsControlledBy(item, app) {
+			children = append(children, ownedChild{kind: "poddisruptionbudget", obj: item})
+		}
+	}
+
+	deploys, err := c.deploymentLister.Deployments(app.Namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range deploys {
+		if metav1.IsControlledBy(item, app) {
+			children = append(children, ownedChild{kind: "deployment", obj: item})
+		}
+	}
+
+	statefulSets, err := c.statefulSetLister.StatefulSets(app.Namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range statefulSets {
+		if metav1.IsControlledBy(item, app) {
+			children = append(children, ownedChild{kind: "statefulset", obj: item})
+		}
+	}
+
+	jobs, err := c.jobLister.Jobs(app.Namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range jobs {
+		if metav1.IsControlledBy(item, app) {
+			children = append(children, ownedChild{kind: "job", obj: item})
+		}
+	}
+
+	cronJobs, err := c.cronJobLister.CronJobs(app.Namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range cronJobs {
+		if metav1.IsControlledBy(item, app) {
+			children = append(children, ownedChild{kind: "cronjob", obj: item})
+		}
+	}
+
+	// listers return objects in no particular order, keep the kind order and sort by name within a kind
+	rank := map[string]int{}
+	for _, child := range children {
+		if _, ok := rank[child.kind]; !ok {
+			rank[child.kind] = len(rank)
+		}
+	}
+	sort.SliceStable(children, func(i, j int) bool {
+		if children[i].kind != children[j].kind {
+			return rank[children[i].kind] < rank[children[j].kind]
+		}
+		return children[i].obj.GetName() < children[j].obj.GetName()
+	})
+	return children, nil
+}
+
+func (c *appController) deleteChild(app *appcontrollerv1.App, child ownedChild) error {
+	// only delete the exact object we listed, never a newer one with the same name
+	uid := child.obj.GetUID()
+	opts := metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &uid}}
+	namespace, name := child.obj.GetNamespace(), child.obj.GetName()
+
+	var err error
+	switch child.kind {
+	case "ingress":
+		err = c.internalClient.NetworkingV1().Ingresses(namespace).Delete(context.TODO(), name, opts)
+	case "service":
+		err = c.internalClient.CoreV1().Services(namespace).Delete(context.TODO(), name, opts)
+	case "horizontalpodautoscaler":
+		err = c.internalClient.AutoscalingV2().HorizontalPodAutoscalers(namespace).Delete(context.TODO(), name, opts)
+	case "poddisruptionbudget":
+		err = c.internalClient.PolicyV1().PodDisruptionBudgets(namespace).Delete(context.TODO(), name, opts)
+	case "deployment":
+		err = c.internalClient.AppsV1().Deployments(namespace).Delete(context.TODO(), name, opts)
+	case "statefulset":
+		err = c.internalClient.AppsV1().StatefulSets(namespace).Delete(context.TODO(), name, opts)
+	case "job":
+		// jobs orphan their pods by default
+		propagation := metav1.DeletePropagationBackground
+		opts.PropagationPolicy = &propagation
+		err = c.internalClient.BatchV1().Jobs(namespace).Delete(context.TODO(), name, opts)
+	case "cronjob":
+		err = c.internalClient.BatchV1().CronJobs(namespace).Delete(context.TODO(), name, opts)
+	}
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err == nil {
+		fmt.Printf("delete %s %s/%s success\n", child.kind, namespace, name)
+		c.recorder.Eventf(app, core.EventTypeNormal, EventReasonDeleted, "Deleted %s %s", child.kind, name)
+	}
+	return err
+}
+
+// orphanChild drops the owner reference to app and the controllerBy label, leaving the child running
+func (c *appController) orphanChild(app *appcontrollerv1.App, child ownedChild) error {
+	ownerReferences := make([]metav1.OwnerReference, 0, len(child.obj.GetOwnerReferences()))
+	for _, ref := range child.obj.GetOwnerReferences() {
+		if ref.UID != app.UID {
+			ownerReferences = append(ownerReferences, ref)
+		}
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"uid":             child.obj.GetUID(),
+			"ownerReferences": ownerReferences,
+			"labels": map[string]interface{}{
+				controllerBy: nil,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	namespace, name := child.obj.GetNamespace(), child.obj.GetName()
+
+	switch child.kind {
+	case "ingress":
+		_, err = c.internalClient.NetworkingV1().Ingresses(namespace).Patch(context.TODO(), name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case "service":
+		_, err = c.internalClient.CoreV1().Services(namespace).Patch(context.TODO(), name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case "horizontalpodautoscaler":
+		_, err = c.internalClient.AutoscalingV2().HorizontalPodAutoscalers(namespace).Patch(context.TODO(), name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case "poddisruptionbudget":
+		_, err = c.internalClient.PolicyV1().PodDisruptionBudgets(namespace).Patch(context.TODO(), name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case "deployment":
+		_, err = c.internalClient.AppsV1().Deployments(namespace).Patch(context.TODO(), name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case "statefulset":
+		_, err = c.internalClient.AppsV1().StatefulSets(namespace).Patch(context.TODO(), name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case "job":
+		_, err = c.internalClient.BatchV1().Jobs(namespace).Patch(context.TODO(), name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case "cronjob":
+		_, err = c.internalClient.BatchV1().CronJobs(namespace).Patch(context.TODO(), name, types.MergePatchType, patch, metav1.PatchOptions{})
+	}
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err == nil {
+		fmt.Printf("orphan %s %s/%s success\n", child.kind, namespace, name)
+		c.recorder.Eventf(app, core.EventTypeNormal, EventReasonOrphaned, "Orphaned %s %s", child.kind, name)
+	}
+	return err
+}
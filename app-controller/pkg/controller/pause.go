@@ -0,0 +1,102 @@
+/*******************************************************************************
+ * @File: pause.go
+ * @Description: pause reconciliation of Apps and report the drift of their workload
+ * @Author: jiangxunyu
+ * @Version: 1.0.0
+ * @Date: 2026/10/21 16:20
+*******************************************************************************/
+
+package controller
+
+import (
+	"fmt"
+
+	appcontrollerv1 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// pausedAnnotation set to "true" pauses an app like spec.paused, without a change of its generation
+const pausedAnnotation = "appcontroller.me/paused"
+
+// isPaused reports whether the children of app are left alone
+func isPaused(app *appcontrollerv1.App) bool {
+	return app.Spec.Paused || app.Annotations[pausedAnnotation] == "true"
+}
+
+// wasPaused reports whether app was paused when its status was last written.
+// The Paused condition stays until the spec has been applied again after a resume.
+func wasPaused(app *appcontrollerv1.App) bool {
+	return meta.IsStatusConditionTrue(app.Status.Conditions, appcontrollerv1.AppPaused)
+}
+
+// pausedMessage describes why app is paused and how far its workload drifted meanwhile
+func pausedMessage(app *appcontrollerv1.App, drift []string) string {
+	by := "spec.paused"
+	if !app.Spec.Paused {
+		by = "the " + pausedAnnotation + " annotation"
+	}
+	if len(drift) == 0 {
+		return fmt.Sprintf("paused by %s, no drift", by)
+	}
+	return fmt.Sprintf("paused by %s, %d fields drifted", by, len(drift))
+}
+
+// workloadDrift returns the drifted fields of the workload of app the way its sync would repair them, without writing anything
+func (c *appController) workloadDrift(app *appcontrollerv1.App) ([]string, error) {
+	var kind string
+	var obj metav1.Object
+	var drift []string
+	var err error
+	name := app.Spec.Deployment.Name
+	switch workloadKind(app) {
+	case appcontrollerv1.StatefulSetWorkloadKind:
+		kind = "statefulset"
+		sts, getErr := c.statefulSetLister.StatefulSets(app.Namespace).Get(name)
+		if err = getErr; err == nil {
+			obj = sts
+			desired := c.constructStatefulSet(app)
+			drift = append(statefulSetImmutableDrift(desired, sts), statefulSetDrift(desired, sts)...)
+		}
+	case appcontrollerv1.JobWorkloadKind:
+		kind = "job"
+		job, getErr := c.jobLister.Jobs(app.Namespace).Get(name)
+		if err = getErr; err == nil {
+			obj = job
+			drift = jobDrift(c.constructJob(app), job)
+		}
+	case appcontrollerv1.CronJobWorkloadKind:
+		kind = "cronjob"
+		cj, getErr := c.cronJobLister.CronJobs(app.Namespace).Get(name)
+		if err = getErr; err == nil {
+			obj = cj
+			drift = cronJobDrift(c.constructCronJob(app), cj)
+		}
+	default:
+		kind = "deployment"
+		deploy, getErr := c.deploymentLister.Deployments(app.Namespace).Get(name)
+		if err = getErr; err == nil {
+			obj = deploy
+			desired := c.constructDeployment(app, app.Spec.Deployment.Replicas)
+			if !equality.Semantic.DeepEqual(desired.Spec.Selector, deploy.Spec.Selector) {
+				drift = append(drift, "spec.selector")
+			}
+			drift = append(drift, deploymentDrift(desired, deploy)...)
+		}
+	}
+	if errors.IsNotFound(err) {
+		return []string{fmt.Sprintf("%s %s: not found", kind, name)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !metav1.IsControlledBy(obj, app) {
+		return []string{fmt.Sprintf("%s %s: not managed by the app", kind, name)}, nil
+	}
+	for i, field := range drift {
+		drift[i] = fmt.Sprintf("%s %s: %s", kind, name, field)
+	}
+	return drift, nil
+}
@@ -0,0 +1,97 @@
+/*******************************************************************************
+ * @File: prune.go
+ * @Description: garbage collection of stale App children
+ * @Author: jiangxunyu
+ * @Version: 1.0.0
+ * @Date: 2026/10/18 22:10
+*******************************************************************************/
+
+package controller
+
+import (
+	"fmt"
+
+	appcontrollerv1 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// WithPruneDryRun makes the controller only log the stale children it would delete.
+// Run in dry-run first to check what gets pruned before enabling deletion.
+func WithPruneDryRun(dryRun bool) Option {
+	return func(c *appController) {
+		c.pruneDryRun = dryRun
+	}
+}
+
+// desiredChildName returns the name the spec of app gives to children of kind
+func desiredChildName(app *appcontrollerv1.App, kind string) string {
+	switch kind {
+	case "deployment":
+		if workloadKind(app) == appcontrollerv1.DeploymentWorkloadKind {
+			return app.Spec.Deployment.Name
+		}
+	case "statefulset":
+		if workloadKind(app) == appcontrollerv1.StatefulSetWorkloadKind {
+			return app.Spec.Deployment.Name
+		}
+	case "job":
+		if workloadKind(app) == appcontrollerv1.JobWorkloadKind {
+			return app.Spec.Deployment.Name
+		}
+	case "cronjob":
+		if workloadKind(app) == appcontrollerv1.CronJobWorkloadKind {
+			return app.Spec.Deployment.Name
+		}
+	case "horizontalpodautoscaler", "poddisruptionbudget":
+		return app.Spec.Deployment.Name
+	case "service":
+		return app.Spec.Service.Name
+	case "ingress":
+		return app.Spec.Ingress.Name
+	}
+	return ""
+}
+
+// isDesiredChild reports whether the spec of app names child
+func isDesiredChild(app *appcontrollerv1.App, child ownedChild) bool {
+	return child.obj.GetName() == desiredChildName(app, child.kind) || isHeadlessService(app, child)
+}
+
+// isComponentChild reports whether the spec of one of components names child
+func isComponentChild(components []*appcontrollerv1.App, child ownedChild) bool {
+	for _, component := range components {
+		if isDesiredChild(component, child) {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneChildren deletes children controlled by app that the spec no longer names, e.g. after a rename.
+// Children of a disabled service, ingress or autoscaler keep their name and are removed by their sync,
+// the workload of the previous kind and its headless service are removed here after a change of the workload kind.
+func (c *appController) pruneChildren(app *appcontrollerv1.App) error {
+	children, err := c.listOwnedChildren(app)
+	if err != nil {
+		return err
+	}
+	components, err := componentApps(app)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, child := range children {
+		if isRolloutChild(app, child) || isDesiredChild(app, child) || isComponentChild(components, child) {
+			// rollout children come and go with the rollout, see syncRolloutChildren
+			continue
+		}
+		if c.pruneDryRun {
+			fmt.Printf("prune %s %s/%s skipped (dry run)\n", child.kind, child.obj.GetNamespace(), child.obj.GetName())
+			continue
+		}
+		if err := c.deleteChild(app, child); err != nil {
+			errs = append(errs, fmt.Errorf("prune %s %s/%s: %v", child.kind, child.obj.GetNamespace(), child.obj.GetName(), err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
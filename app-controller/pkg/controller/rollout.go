@@ -0,0 +1,429 @@
+/*******************************************************************************
+ * @File: rollout.go
+ * @Description: canary and blue/green rollouts of App images
+ * @Author: jiangxunyu
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 11:20
+*******************************************************************************/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	appcontrollerv1 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v1"
+	deployapps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	net "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// rollout commands, set as annotations on the App, e.g. `kubectl annotate app demo appcontroller.me/promote=true`.
+// They are removed once the controller has acted on them.
+const (
+	// promoteAnnotation resumes a paused canary step, or switches a blue/green rollout to the preview
+	promoteAnnotation = "appcontroller.me/promote"
+	// abortAnnotation drops the new image and keeps the stable one serving
+	abortAnnotation = "appcontroller.me/abort"
+)
+
+// name suffixes of the children brought up next to the stable ones during a rollout
+const (
+	canarySuffix  = "-canary"
+	previewSuffix = "-preview"
+)
+
+// nginx ingress annotations splitting traffic to a canary ingress
+const (
+	nginxCanaryAnnotation       = "nginx.ingress.kubernetes.io/canary"
+	nginxCanaryWeightAnnotation = "nginx.ingress.kubernetes.io/canary-weight"
+)
+
+func rolloutStrategy(app *appcontrollerv1.App) appcontrollerv1.RolloutStrategyType {
+	if app.Spec.Strategy.Type == "" {
+		return appcontrollerv1.RollingUpdateStrategyType
+	}
+	return app.Spec.Strategy.Type
+}
+
+// rolloutActive reports whether the canary or preview children of a rollout are needed
+func rolloutActive(rollout *appcontrollerv1.RolloutStatus) bool {
+	if rollout == nil {
+		return false
+	}
+	switch rollout.Phase {
+	case appcontrollerv1.RolloutProgressing, appcontrollerv1.RolloutPaused, appcontrollerv1.RolloutPromoting:
+		return true
+	}
+	return false
+}
+
+// rolloutSuffix returns the name suffix of the rollout children of app
+func rolloutSuffix(app *appcontrollerv1.App) string {
+	if rolloutStrategy(app) == appcontrollerv1.BlueGreenStrategyType {
+		return previewSuffix
+	}
+	return canarySuffix
+}
+
+// deploymentImage returns the image of the stable deployment, which only follows the spec once a rollout promoted it
+func deploymentImage(app *appcontrollerv1.App) string {
+	if rollout := app.Status.Rollout; rollout != nil && rollout.StableImage != "" {
+		return rollout.StableImage
+	}
+	return app.Spec.Deployment.Image
+}
+
+// serviceSelectorSuffix returns the suffix of the pods selected by the app service,
+// a promoted blue/green rollout serves from the preview until the stable deployment has rolled out
+func serviceSelectorSuffix(app *appcontrollerv1.App) string {
+	if rollout := app.Status.Rollout; rollout != nil && rollout.Phase == appcontrollerv1.RolloutPromoting &&
+		rolloutStrategy(app) == appcontrollerv1.BlueGreenStrategyType {
+		return previewSuffix
+	}
+	return ""
+}
+
+// syncRollout moves the rollout of app one step forward and returns its new state, nil for the RollingUpdate strategy.
+// It only decides the state, the children follow it in syncChildren.
+func (c *appController) syncRollout(app *appcontrollerv1.App) (*appcontrollerv1.RolloutStatus, error) {
+	if rolloutStrategy(app) == appcontrollerv1.RollingUpdateStrategyType {
+		return nil, nil
+	}
+	rollout := app.Status.Rollout.DeepCopy()
+	if rollout == nil {
+		image, err := c.liveImage(app)
+		if err != nil {
+			return nil, err
+		}
+		rollout = &appcontrollerv1.RolloutStatus{Phase: appcontrollerv1.RolloutHealthy, StableImage: image}
+	}
+
+	image := app.Spec.Deployment.Image
+	switch {
+	case image == rollout.StableImage && rollout.Phase != appcontrollerv1.RolloutPromoting:
+		if rollout.Phase != appcontrollerv1.RolloutHealthy {
+			// the spec went back to the stable image
+			fmt.Printf("rollout of app %s/%s back on stable image %s\n", app.Namespace, app.Name, image)
+		}
+		return &appcontrollerv1.RolloutStatus{Phase: appcontrollerv1.RolloutHealthy, StableImage: image}, nil
+	case image != rollout.UpdatedImage:
+		rollout.Phase = appcontrollerv1.RolloutProgressing
+		rollout.UpdatedImage = image
+		rollout.CurrentStep = 0
+		rollout.CurrentWeight = 0
+		rollout.PauseStartTime = nil
+		rollout.Message = fmt.Sprintf("rolling out %s", image)
+		c.recorder.Eventf(app, core.EventTypeNormal, EventReasonRolloutStarted, "Started %s rollout of image %s", rolloutStrategy(app), image)
+	case rollout.Phase == appcontrollerv1.RolloutAborted:
+		// stays aborted until the spec changes the image again
+		return rollout, nil
+	}
+
+	if _, ok := app.Annotations[abortAnnotation]; ok && rollout.Phase != appcontrollerv1.RolloutPromoting {
+		rollout.Phase = appcontrollerv1.RolloutAborted
+		rollout.CurrentWeight = 0
+		rollout.PauseStartTime = nil
+		rollout.Message = fmt.Sprintf("rollout of %s aborted, %s keeps serving", rollout.UpdatedImage, rollout.StableImage)
+		c.recorder.Eventf(app, core.EventTypeWarning, EventReasonAborted, "Aborted rollout of image %s", rollout.UpdatedImage)
+		return rollout, nil
+	}
+	_, promote := app.Annotations[promoteAnnotation]
+
+	if rolloutStrategy(app) == appcontrollerv1.BlueGreenStrategyType {
+		return c.stepBlueGreen(app, rollout, promote)
+	}
+	return c.stepCanary(app, rollout, promote)
+}
+
+// stepCanary walks through the canary steps. A step shifts its weight to the canary once it is available,
+// then waits for its pause to elapse, or for the promote annotation when it has no pause.
+func (c *appController) stepCanary(app *appcontrollerv1.App, rollout *appcontrollerv1.RolloutStatus, promote bool) (*appcontrollerv1.RolloutStatus, error) {
+	var steps []appcontrollerv1.CanaryStep
+	if app.Spec.Strategy.Canary != nil {
+		steps = app.Spec.Strategy.Canary.Steps
+	}
+	available, err := c.rolloutDeploymentAvailable(app, rollout.UpdatedImage)
+	if err != nil {
+		return nil, err
+	}
+	if promote {
+		rollout.CurrentStep++
+		rollout.PauseStartTime = nil
+	}
+
+	for int(rollout.CurrentStep) < len(steps) {
+		step := steps[rollout.CurrentStep]
+		if !available {
+			// the canary keeps the weight of the previous step until its pods run the new image
+			rollout.Phase = appcontrollerv1.RolloutProgressing
+			rollout.Message = fmt.Sprintf("step %d: waiting for the canary to become available", rollout.CurrentStep)
+			return rollout, nil
+		}
+		rollout.CurrentWeight = step.Weight
+		if step.Pause == nil {
+			rollout.Phase = appcontrollerv1.RolloutPaused
+			rollout.Message = fmt.Sprintf("step %d: %d%% of the traffic on the canary, waiting for promotion", rollout.CurrentStep, step.Weight)
+			return rollout, nil
+		}
+		if rollout.PauseStartTime == nil {
+			now := metav1.Now()
+			rollout.PauseStartTime = &now
+		}
+		if remaining := time.Until(rollout.PauseStartTime.Add(step.Pause.Duration)); remaining > 0 {
+			rollout.Phase = appcontrollerv1.RolloutProgressing
+			rollout.Message = fmt.Sprintf("step %d: %d%% of the traffic on the canary, pausing for %s", rollout.CurrentStep, step.Weight, step.Pause.Duration)
+			c.queue.AddAfter(app.Namespace+"/"+app.Name, remaining)
+			return rollout, nil
+		}
+		rollout.CurrentStep++
+		rollout.PauseStartTime = nil
+	}
+
+	// every step passed, the stable deployment takes the new image
+	c.recorder.Eventf(app, core.EventTypeNormal, EventReasonPromoted, "Promoted image %s", rollout.UpdatedImage)
+	return &appcontrollerv1.RolloutStatus{
+		Phase:       appcontrollerv1.RolloutHealthy,
+		StableImage: rollout.UpdatedImage,
+		Message:     fmt.Sprintf("promoted %s", rollout.UpdatedImage),
+	}, nil
+}
+
+// stepBlueGreen brings up the preview and, once promoted, serves from it while the stable deployment rolls out
+func (c *appController) stepBlueGreen(app *appcontrollerv1.App, rollout *appcontrollerv1.RolloutStatus, promote bool) (*appcontrollerv1.RolloutStatus, error) {
+	if rollout.Phase == appcontrollerv1.RolloutPromoting {
+		deploy, err := c.deploymentLister.Deployments(app.Namespace).Get(app.Spec.Deployment.Name)
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+		if err != nil || !deploymentRolledOut(deploy, app.Spec.Deployment.Name, rollout.StableImage) {
+			return rollout, nil
+		}
+		// the service switches back to the stable deployment and the preview is torn down
+		c.recorder.Eventf(app, core.EventTypeNormal, EventReasonRolloutCompleted, "Rolled out image %s", rollout.StableImage)
+		return &appcontrollerv1.RolloutStatus{
+			Phase:       appcontrollerv1.RolloutHealthy,
+			StableImage: rollout.StableImage,
+			Message:     fmt.Sprintf("promoted %s", rollout.StableImage),
+		}, nil
+	}
+
+	available, err := c.rolloutDeploymentAvailable(app, rollout.UpdatedImage)
+	if err != nil {
+		return nil, err
+	}
+	autoPromote := app.Spec.Strategy.BlueGreen != nil && app.Spec.Strategy.BlueGreen.AutoPromote
+	switch {
+	case promote || (available && autoPromote):
+		rollout.Phase = appcontrollerv1.RolloutPromoting
+		rollout.StableImage = rollout.UpdatedImage
+		rollout.Message = "service switched to the preview while the stable deployment rolls out"
+		c.recorder.Eventf(app, core.EventTypeNormal, EventReasonPromoted, "Promoted image %s", rollout.UpdatedImage)
+	case available:
+		rollout.Phase = appcontrollerv1.RolloutPaused
+		rollout.Message = "preview is available, waiting for promotion"
+	default:
+		rollout.Phase = appcontrollerv1.RolloutProgressing
+		rollout.Message = "waiting for the preview to become available"
+	}
+	return rollout, nil
+}
+
+// liveImage returns the image the stable deployment runs, or the spec image when there is no deployment yet
+func (c *appController) liveImage(app *appcontrollerv1.App) (string, error) {
+	deploy, err := c.deploymentLister.Deployments(app.Namespace).Get(app.Spec.Deployment.Name)
+	if errors.IsNotFound(err) {
+		return app.Spec.Deployment.Image, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, container := range deploy.Spec.Template.Spec.Containers {
+		if container.Name == app.Spec.Deployment.Name {
+			return container.Image, nil
+		}
+	}
+	return app.Spec.Deployment.Image, nil
+}
+
+// rolloutDeploymentAvailable reports whether the canary or preview deployment runs image on all of its replicas
+func (c *appController) rolloutDeploymentAvailable(app *appcontrollerv1.App, image string) (bool, error) {
+	deploy, err := c.deploymentLister.Deployments(app.Namespace).Get(app.Spec.Deployment.Name + rolloutSuffix(app))
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return deploymentRolledOut(deploy, app.Spec.Deployment.Name, image), nil
+}
+
+// deploymentRolledOut reports whether every replica of deploy is updated to image and ready
+func deploymentRolledOut(deploy *deployapps.Deployment, container, image string) bool {
+	replicas := int32(1)
+	if deploy.Spec.Replicas != nil {
+		replicas = *deploy.Spec.Replicas
+	}
+	if deploy.Status.ObservedGeneration < deploy.Generation ||
+		deploy.Status.UpdatedReplicas < replicas || deploy.Status.ReadyReplicas < replicas {
+		return false
+	}
+	for _, c := range deploy.Spec.Template.Spec.Containers {
+		if c.Name == container {
+			return c.Image == image
+		}
+	}
+	return false
+}
+
+// syncRolloutChildren applies the canary or preview children while a rollout is active and deletes them otherwise
+func (c *appController) syncRolloutChildren(app *appcontrollerv1.App) error {
+	if !rolloutActive(app.Status.Rollout) {
+		return c.deleteRolloutChildren(app)
+	}
+	if err := c.applyDeployment(c.constructRolloutDeployment(app)); err != nil {
+		return err
+	}
+	if !app.Spec.Service.Enabled {
+		return nil
+	}
+	if err := c.applyService(c.constructRolloutService(app)); err != nil {
+		return err
+	}
+	if rolloutStrategy(app) == appcontrollerv1.CanaryStrategyType && app.Spec.Ingress.Enabled {
+		return c.applyIngress(c.constructCanaryIngress(app))
+	}
+	return nil
+}
+
+// deleteRolloutChildren deletes the canary and preview children left by a finished or aborted rollout
+func (c *appController) deleteRolloutChildren(app *appcontrollerv1.App) error {
+	children, err := c.listOwnedChildren(app)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, child := range children {
+		if !isRolloutChild(app, child) {
+			continue
+		}
+		if err := c.deleteChild(app, child); err != nil {
+			errs = append(errs, fmt.Errorf("%s %s/%s: %v", child.kind, child.obj.GetNamespace(), child.obj.GetName(), err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// isRolloutChild reports whether child is the canary or preview counterpart of a stable child of app
+func isRolloutChild(app *appcontrollerv1.App, child ownedChild) bool {
+	name := child.obj.GetName()
+	for _, suffix := range []string{canarySuffix, previewSuffix} {
+		if (child.kind == "deployment" || child.kind == "service" || child.kind == "ingress") && strings.TrimSuffix(name, suffix) != name &&
+			strings.TrimSuffix(name, suffix) == desiredChildName(app, child.kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// rolloutLabels select the pods of the canary or preview deployment, apart from the stable pods
+func rolloutLabels(app *appcontrollerv1.App) map[string]string {
+	return map[string]string{
+		"app":        app.Name,
+		"controller": selectorName(app) + rolloutSuffix(app),
+	}
+}
+
+func (c *appController) constructRolloutDeployment(app *appcontrollerv1.App) *deployapps.Deployment {
+	deploy := c.constructDeployment(app, 0)
+	deploy.Name = app.Spec.Deployment.Name + rolloutSuffix(app)
+	deploy.Spec.Selector = &metav1.LabelSelector{MatchLabels: rolloutLabels(app)}
+	deploy.Spec.Template.Labels = rolloutLabels(app)
+	deploy.Spec.Template.Spec.Containers[0].Image = app.Status.Rollout.UpdatedImage
+
+	replicas := app.Spec.Deployment.Replicas
+	if rolloutStrategy(app) == appcontrollerv1.CanaryStrategyType {
+		replicas = 1
+		if canary := app.Spec.Strategy.Canary; canary != nil && canary.Replicas != nil {
+			replicas = *canary.Replicas
+		}
+	} else if autoscalingEnabled(app) {
+		// the preview takes over all traffic on promotion, size it like the autoscaled stable deployment
+		if live, err := c.deploymentLister.Deployments(app.Namespace).Get(app.Spec.Deployment.Name); err == nil && live.Spec.Replicas != nil {
+			replicas = *live.Spec.Replicas
+		}
+	}
+	deploy.Spec.Replicas = &replicas
+	return deploy
+}
+
+func (c *appController) constructRolloutService(app *appcontrollerv1.App) *core.Service {
+	svc := c.constructService(app)
+	svc.Name = app.Spec.Service.Name + rolloutSuffix(app)
+	svc.Spec.Selector = rolloutLabels(app)
+	// only reached in cluster, through the canary ingress or for testing the preview
+	if svc.Spec.ClusterIP != core.ClusterIPNone {
+		svc.Spec.Type = core.ServiceTypeClusterIP
+	}
+	for i := range svc.Spec.Ports {
+		svc.Spec.Ports[i].NodePort = 0
+	}
+	return svc
+}
+
+// constructCanaryIngress mirrors the app ingress to the canary service, weighted by the current canary step
+func (c *appController) constructCanaryIngress(app *appcontrollerv1.App) *net.Ingress {
+	ing := c.constructIngress(app)
+	ing.Name = app.Spec.Ingress.Name + canarySuffix
+	annotations := make(map[string]string, len(ing.Annotations)+2)
+	for k, v := range ing.Annotations {
+		annotations[k] = v
+	}
+	annotations[nginxCanaryAnnotation] = "true"
+	annotations[nginxCanaryWeightAnnotation] = strconv.Itoa(int(app.Status.Rollout.CurrentWeight))
+	ing.Annotations = annotations
+	for _, rule := range ing.Spec.Rules {
+		for i := range rule.HTTP.Paths {
+			if backend := rule.HTTP.Paths[i].Backend.Service; backend != nil && backend.Name == app.Spec.Service.Name {
+				backend.Name = app.Spec.Service.Name + canarySuffix
+			}
+		}
+	}
+	return ing
+}
+
+// clearRolloutCommands removes the promote and abort annotations the coming sync acts on.
+// app keeps them for the sync and takes the resource version of the patched app, so its status can still be written.
+func (c *appController) clearRolloutCommands(app *appcontrollerv1.App) error {
+	annotations := map[string]interface{}{}
+	for _, key := range []string{promoteAnnotation, abortAnnotation} {
+		if _, ok := app.Annotations[key]; ok {
+			annotations[key] = nil
+		}
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	patched, err := c.appClient.AppcontrollerV1().Apps(app.Namespace).Patch(context.TODO(), app.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return err
+	}
+	app.ResourceVersion = patched.ResourceVersion
+	return nil
+}
@@ -0,0 +1,295 @@
+/*******************************************************************************
+ * @File: statefulset.go
+ * @Description: StatefulSet workload of Apps and its headless service
+ * @Author: jiangxunyu
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 15:10
+*******************************************************************************/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appcontrollerv1 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v1"
+	deployapps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// headlessSuffix names the governing service of a statefulset after it, the validation of the service name relies on it
+const headlessSuffix = "-headless"
+
+// workloadKind returns the kind of workload running the pods of app
+func workloadKind(app *appcontrollerv1.App) appcontrollerv1.WorkloadKind {
+	if app.Spec.Deployment.WorkloadKind == "" {
+		return appcontrollerv1.DeploymentWorkloadKind
+	}
+	return app.Spec.Deployment.WorkloadKind
+}
+
+// headlessServiceName returns the name of the service governing the statefulset of app
+func headlessServiceName(app *appcontrollerv1.App) string {
+	return app.Spec.Deployment.Name + headlessSuffix
+}
+
+// isHeadlessService reports whether child is the service governing the statefulset of app
+func isHeadlessService(app *appcontrollerv1.App, child ownedChild) bool {
+	return workloadKind(app) == appcontrollerv1.StatefulSetWorkloadKind && child.kind == "service" &&
+		child.obj.GetName() == headlessServiceName(app)
+}
+
+// syncStatefulSet creates the statefulset of app together with its headless service, or repairs its drift
+func (c *appController) syncStatefulSet(app *appcontrollerv1.App) error {
+	// the pods get their stable dns names from the governing service, so it comes first
+	if err := c.syncHeadlessService(app); err != nil {
+		return err
+	}
+	sts, err := c.statefulSetLister.StatefulSets(app.Namespace).Get(app.Spec.Deployment.Name)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if errors.IsNotFound(err) {
+		// create statefulset
+		if err := c.applyStatefulSet(c.constructStatefulSet(app)); err != nil {
+			return err
+		}
+		fmt.Println("create statefulset success")
+		c.recorder.Eventf(app, core.EventTypeNormal, EventReasonCreated, "Created statefulset %s", app.Spec.Deployment.Name)
+		return nil
+	}
+	// repair any drift on the fields owned by the app
+	_, err = c.repairStatefulSet(app, sts)
+	return err
+}
+
+func (c *appController) syncHeadlessService(app *appcontrollerv1.App) error {
+	name := headlessServiceName(app)
+	return c.syncChild(app, childSync{
+		kind:    "service",
+		name:    name,
+		enabled: true,
+		get:     func() (metav1.Object, error) { return c.serviceLister.Services(app.Namespace).Get(name) },
+		apply:   func() error { return c.applyService(c.constructHeadlessService(app)) },
+	})
+}
+
+// repairStatefulSet brings the fields of sts owned by app back to the desired state and returns the drifted fields
+func (c *appController) repairStatefulSet(app *appcontrollerv1.App, sts *deployapps.StatefulSet) ([]string, error) {
+	if !metav1.IsControlledBy(sts, app) {
+		return nil, fmt.Errorf("statefulset %s/%s already exists and is not managed by app %s", sts.Namespace, sts.Name, app.Name)
+	}
+	desired := c.constructStatefulSet(app)
+
+	// an immutable field needs a new statefulset, which adopts the orphaned pods and their claims
+	if immutable := statefulSetImmutableDrift(desired, sts); len(immutable) > 0 {
+		uid := sts.UID
+		orphan := metav1.DeletePropagationOrphan
+		err := c.internalClient.AppsV1().StatefulSets(sts.Namespace).Delete(context.TODO(), sts.Name, metav1.DeleteOptions{
+			Preconditions:     &metav1.Preconditions{UID: &uid},
+			PropagationPolicy: &orphan,
+		})
+		if err != nil {
+			return nil, err
+		}
+		c.recorder.Eventf(app, core.EventTypeNormal, EventReasonDeleted, "Deleted statefulset %s to recreate it with a new %s",
+			sts.Name, strings.Join(immutable, ", "))
+		return []string{strings.Join(immutable, ", ") + " (recreate)"}, nil
+	}
+
+	drift := statefulSetDrift(desired, sts)
+	if len(drift) == 0 && !specChanged(app) {
+		return nil, nil
+	}
+	if autoscalingEnabled(app) {
+		if err := c.handoverReplicas(sts); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.applyStatefulSet(desired); err != nil {
+		return nil, err
+	}
+	if len(drift) > 0 {
+		fmt.Printf("repair statefulset %s/%s drift: %s\n", sts.Namespace, sts.Name, strings.Join(drift, ", "))
+		c.recorder.Eventf(app, core.EventTypeNormal, EventReasonUpdated, "Repaired drift of statefulset %s: %s", sts.Name, strings.Join(drift, ", "))
+	} else {
+		c.recorder.Eventf(app, core.EventTypeNormal, EventReasonUpdated, "Updated statefulset %s", sts.Name)
+	}
+	return drift, nil
+}
+
+// statefulSetImmutableDrift compares the immutable fields of desired and live: the selector, service name and claim templates
+func statefulSetImmutableDrift(desired, live *deployapps.StatefulSet) []string {
+	var drift []string
+	if !equality.Semantic.DeepEqual(desired.Spec.Selector, live.Spec.Selector) {
+		drift = append(drift, "spec.selector")
+	}
+	if desired.Spec.ServiceName != live.Spec.ServiceName {
+		drift = append(drift, "spec.serviceName")
+	}
+	if claimTemplatesDrifted(desired.Spec.VolumeClaimTemplates, live.Spec.VolumeClaimTemplates) {
+		drift = append(drift, "spec.volumeClaimTemplates")
+	}
+	return drift
+}
+
+// statefulSetDrift compares the fields owned by the controller in desired and live, the same way as deploymentDrift
+func statefulSetDrift(desired, live *deployapps.StatefulSet) []string {
+	var drift []string
+	if labelsDrifted(live.Labels, desired.Labels) {
+		drift = append(drift, "metadata.labels")
+	}
+	if desired.Spec.Replicas != nil && (live.Spec.Replicas == nil || *live.Spec.Replicas != *desired.Spec.Replicas) {
+		drift = append(drift, "spec.replicas")
+	}
+	return append(drift, podTemplateDrift(desired.Spec.Template, live.Spec.Template)...)
+}
+
+// claimTemplatesDrifted reports whether the claim templates of live differ from desired in a field set by the app
+func claimTemplatesDrifted(desired, live []core.PersistentVolumeClaim) bool {
+	if len(desired) != len(live) {
+		return true
+	}
+	for i := range desired {
+		want, got := desired[i], live[i]
+		if want.Name != got.Name ||
+			!equality.Semantic.DeepEqual(want.Spec.AccessModes, got.Spec.AccessModes) ||
+			!equality.Semantic.DeepEqual(want.Spec.Resources.Requests, got.Spec.Resources.Requests) {
+			return true
+		}
+		if want.Spec.StorageClassName != nil && (got.Spec.StorageClassName == nil || *got.Spec.StorageClassName != *want.Spec.StorageClassName) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *appController) constructStatefulSet(app *appcontrollerv1.App) *deployapps.StatefulSet {
+	labels := map[string]string{
+		"app":        app.Name,
+		"controller": selectorName(app),
+	}
+	replicas := app.Spec.Deployment.Replicas
+	sts := &deployapps.StatefulSet{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: deployapps.SchemeGroupVersion.String(),
+			Kind:       "StatefulSet",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      app.Spec.Deployment.Name,
+			Namespace: app.Namespace,
+			Labels: map[string]string{
+				controllerBy: app.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(app, appcontrollerv1.SchemeGroupVersion.WithKind("App")),
+			},
+		},
+		Spec: deployapps.StatefulSetSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+			Template:    c.constructPodTemplate(app, labels),
+			ServiceName: headlessServiceName(app),
+		},
+	}
+	for _, claim := range app.Spec.Deployment.VolumeClaimTemplates {
+		sts.Spec.VolumeClaimTemplates = append(sts.Spec.VolumeClaimTemplates, core.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        claim.Name,
+				Labels:      claim.Labels,
+				Annotations: claim.Annotations,
+			},
+			Spec: *claim.Spec.DeepCopy(),
+		})
+	}
+	if autoscalingEnabled(app) {
+		// leave replicas to the autoscaler, applying them would scale the statefulset back on every sync
+		sts.Spec.Replicas = nil
+	}
+	return sts
+}
+
+// constructHeadlessService builds the service governing the statefulset of app, publishing the pods before they are ready
+func (c *appController) constructHeadlessService(app *appcontrollerv1.App) *core.Service {
+	var ports []core.ServicePort
+	for _, port := range containerPorts(app) {
+		ports = append(ports, core.ServicePort{
+			Name:       port.Name,
+			Protocol:   port.Protocol,
+			Port:       port.ContainerPort,
+			TargetPort: intstr.FromInt(int(port.ContainerPort)),
+		})
+	}
+	return &core.Service{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: core.SchemeGroupVersion.String(),
+			Kind:       "Service",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      headlessServiceName(app),
+			Namespace: app.Namespace,
+			Labels: map[string]string{
+				controllerBy: app.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(app, appcontrollerv1.SchemeGroupVersion.WithKind("App")),
+			},
+		},
+		Spec: core.ServiceSpec{
+			Selector: map[string]string{
+				"app":        app.Name,
+				"controller": selectorName(app),
+			},
+			ClusterIP:                core.ClusterIPNone,
+			Ports:                    ports,
+			PublishNotReadyAddresses: true,
+		},
+	}
+}
+
+// statefulSetStatus reports the statefulset of app in status and returns whether it is available
+func (c *appController) statefulSetStatus(app *appcontrollerv1.App, status *appcontrollerv1.AppStatus) (bool, error) {
+	sts, err := c.statefulSetLister.StatefulSets(app.Namespace).Get(app.Spec.Deployment.Name)
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	if errors.IsNotFound(err) {
+		c.setCondition(app, status, appcontrollerv1.AppDeploymentAvailable, metav1.ConditionFalse, reasonNotFound,
+			fmt.Sprintf("statefulset %s not found", app.Spec.Deployment.Name))
+		return false, nil
+	}
+	status.Replicas = sts.Status.Replicas
+	status.ReadyReplicas = sts.Status.ReadyReplicas
+	desired := app.Spec.Deployment.Replicas
+	if autoscalingEnabled(app) && sts.Spec.Replicas != nil {
+		// the autoscaler decides the replicas
+		desired = *sts.Spec.Replicas
+	}
+	if !isStatefulSetAvailable(sts) {
+		c.setCondition(app, status, appcontrollerv1.AppDeploymentAvailable, metav1.ConditionFalse, reasonUnavailable,
+			fmt.Sprintf("%d/%d replicas ready", sts.Status.ReadyReplicas, desired))
+		return false, nil
+	}
+	c.setCondition(app, status, appcontrollerv1.AppDeploymentAvailable, metav1.ConditionTrue, reasonAvailable,
+		fmt.Sprintf("%d/%d replicas ready", sts.Status.ReadyReplicas, desired))
+	return true, nil
+}
+
+// isStatefulSetAvailable reports whether every replica of the latest spec is available, statefulsets have no Available condition
+func isStatefulSetAvailable(sts *deployapps.StatefulSet) bool {
+	if sts.Status.ObservedGeneration < sts.Generation {
+		return false
+	}
+	replicas := int32(1)
+	if sts.Spec.Replicas != nil {
+		replicas = *sts.Spec.Replicas
+	}
+	return sts.Status.AvailableReplicas >= replicas
+}
@@ -0,0 +1,238 @@
+/*******************************************************************************
+ * @File: status.go
+ * @Description: compute and write back App status
+ * @Author: jiangxunyu
+ * @Version: 1.0.0
+ * @Date: 2026/10/18 10:12
+*******************************************************************************/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appcontrollerv1 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v1"
+	deployapps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// condition reasons
+const (
+	reasonAvailable        = "Available"
+	reasonUnavailable      = "Unavailable"
+	reasonNotFound         = "NotFound"
+	reasonCreated          = "Created"
+	reasonAddressAssigned  = "AddressAssigned"
+	reasonAddressPending   = "AddressPending"
+	reasonChildrenReady    = "ChildrenReady"
+	reasonChildrenNotReady = "ChildrenNotReady"
+	reasonSyncFailed       = "SyncFailed"
+	reasonInvalidSpec      = "InvalidSpec"
+	reasonComplete         = "Complete"
+	reasonFailed           = "Failed"
+	reasonRunning          = "Running"
+	reasonScheduled        = "Scheduled"
+	reasonPaused           = "Paused"
+)
+
+// invalidSpecError is reported when the app spec is rejected by validation
+type invalidSpecError struct {
+	error
+}
+
+// updateAppStatus writes the observed state of app's children through the status subresource.
+// rollout is the rollout state decided by the preceding sync and syncErr the error it returned, if any.
+func (c *appController) updateAppStatus(app *appcontrollerv1.App, rollout *appcontrollerv1.RolloutStatus, syncErr error) error {
+	status, err := c.computeAppStatus(app, syncErr)
+	if err != nil {
+		return err
+	}
+	status.Rollout = rollout
+	if equality.Semantic.DeepEqual(&app.Status, status) {
+		return nil
+	}
+	newApp := app.DeepCopy()
+	newApp.Status = *status
+	_, err = c.appClient.AppcontrollerV1().Apps(app.Namespace).UpdateStatus(context.TODO(), newApp, metav1.UpdateOptions{})
+	return err
+}
+
+func (c *appController) computeAppStatus(app *appcontrollerv1.App, syncErr error) (*appcontrollerv1.AppStatus, error) {
+	// start from the current status so unchanged conditions keep their transition time
+	status := app.Status.DeepCopy()
+	// a failed or paused sync has not fully applied the spec yet, keep it pending so the next sync applies it again
+	paused := isPaused(app)
+	if syncErr == nil && !paused {
+		status.ObservedGeneration = app.Generation
+	}
+	// the scale subresource reads the observed replicas, the desired count is in the spec
+	status.Replicas = 0
+	status.ReadyReplicas = 0
+	status.DeploymentName = app.Spec.Deployment.Name
+	// the scale subresource reports the pods of the stable deployment
+	status.Selector = labels.SelectorFromSet(labels.Set{
+		"app":        app.Name,
+		"controller": selectorName(app),
+	}).String()
+	status.ServiceName = ""
+	status.IngressName = ""
+
+	// workload
+	if !isBatchWorkload(app) {
+		status.LastScheduleTime = nil
+		status.LastSuccessfulTime = nil
+		status.LastFailureTime = nil
+	}
+	var ready bool
+	var err error
+	switch workloadKind(app) {
+	case appcontrollerv1.StatefulSetWorkloadKind:
+		ready, err = c.statefulSetStatus(app, status)
+	case appcontrollerv1.JobWorkloadKind:
+		ready, err = c.jobStatus(app, status)
+	case appcontrollerv1.CronJobWorkloadKind:
+		ready, err = c.cronJobStatus(app, status)
+	default:
+		ready, err = c.deploymentStatus(app, status)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// service
+	if app.Spec.Service.Enabled {
+		status.ServiceName = app.Spec.Service.Name
+		_, err := c.serviceLister.Services(app.Namespace).Get(app.Spec.Service.Name)
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+		if errors.IsNotFound(err) {
+			ready = false
+			c.setCondition(app, status, appcontrollerv1.AppServiceReady, metav1.ConditionFalse, reasonNotFound,
+				fmt.Sprintf("service %s not found", app.Spec.Service.Name))
+		} else {
+			c.setCondition(app, status, appcontrollerv1.AppServiceReady, metav1.ConditionTrue, reasonCreated,
+				fmt.Sprintf("service %s created", app.Spec.Service.Name))
+		}
+	} else {
+		meta.RemoveStatusCondition(&status.Conditions, appcontrollerv1.AppServiceReady)
+	}
+
+	// ingress, only managed together with the service
+	if app.Spec.Service.Enabled && app.Spec.Ingress.Enabled {
+		status.IngressName = app.Spec.Ingress.Name
+		ingress, err := c.ingressLister.Ingresses(app.Namespace).Get(app.Spec.Ingress.Name)
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+		if errors.IsNotFound(err) {
+			ready = false
+			c.setCondition(app, status, appcontrollerv1.AppIngressReady, metav1.ConditionFalse, reasonNotFound,
+				fmt.Sprintf("ingress %s not found", app.Spec.Ingress.Name))
+		} else if len(ingress.Status.LoadBalancer.Ingress) == 0 {
+			ready = false
+			c.setCondition(app, status, appcontrollerv1.AppIngressReady, metav1.ConditionFalse, reasonAddressPending,
+				fmt.Sprintf("ingress %s has no address yet", app.Spec.Ingress.Name))
+		} else {
+			c.setCondition(app, status, appcontrollerv1.AppIngressReady, metav1.ConditionTrue, reasonAddressAssigned,
+				fmt.Sprintf("ingress %s has address %s", app.Spec.Ingress.Name, loadBalancerAddress(ingress.Status.LoadBalancer.Ingress[0])))
+		}
+	} else {
+		meta.RemoveStatusCondition(&status.Conditions, appcontrollerv1.AppIngressReady)
+	}
+
+	// components after the first one of an app written as v2
+	componentsReady, componentsDrift, err := c.componentsStatus(app, status, syncErr)
+	if err != nil {
+		return nil, err
+	}
+	ready = ready && componentsReady
+
+	// pause, the condition is only dropped once the spec has been applied again, see specChanged
+	if paused {
+		drift, err := c.workloadDrift(app)
+		if err != nil {
+			return nil, err
+		}
+		status.Drift = append(drift, componentsDrift...)
+		c.setCondition(app, status, appcontrollerv1.AppPaused, metav1.ConditionTrue, reasonPaused, pausedMessage(app, drift))
+	} else if syncErr == nil {
+		status.Drift = nil
+		meta.RemoveStatusCondition(&status.Conditions, appcontrollerv1.AppPaused)
+	}
+
+	// aggregated ready
+	_, invalid := syncErr.(invalidSpecError)
+	switch {
+	case invalid:
+		c.setCondition(app, status, appcontrollerv1.AppReady, metav1.ConditionFalse, reasonInvalidSpec, syncErr.Error())
+	case syncErr != nil:
+		c.setCondition(app, status, appcontrollerv1.AppReady, metav1.ConditionFalse, reasonSyncFailed, syncErr.Error())
+	case ready:
+		c.setCondition(app, status, appcontrollerv1.AppReady, metav1.ConditionTrue, reasonChildrenReady, "all children are ready")
+	default:
+		c.setCondition(app, status, appcontrollerv1.AppReady, metav1.ConditionFalse, reasonChildrenNotReady, "waiting for children to become ready")
+	}
+	return status, nil
+}
+
+// deploymentStatus reports the deployment of app in status and returns whether it is available
+func (c *appController) deploymentStatus(app *appcontrollerv1.App, status *appcontrollerv1.AppStatus) (bool, error) {
+	deploy, err := c.deploymentLister.Deployments(app.Namespace).Get(app.Spec.Deployment.Name)
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	if errors.IsNotFound(err) {
+		c.setCondition(app, status, appcontrollerv1.AppDeploymentAvailable, metav1.ConditionFalse, reasonNotFound,
+			fmt.Sprintf("deployment %s not found", app.Spec.Deployment.Name))
+		return false, nil
+	}
+	status.Replicas = deploy.Status.Replicas
+	status.ReadyReplicas = deploy.Status.ReadyReplicas
+	desired := app.Spec.Deployment.Replicas
+	if autoscalingEnabled(app) && deploy.Spec.Replicas != nil {
+		// the autoscaler decides the replicas
+		desired = *deploy.Spec.Replicas
+	}
+	if !isDeploymentAvailable(deploy) {
+		c.setCondition(app, status, appcontrollerv1.AppDeploymentAvailable, metav1.ConditionFalse, reasonUnavailable,
+			fmt.Sprintf("%d/%d replicas ready", deploy.Status.ReadyReplicas, desired))
+		return false, nil
+	}
+	c.setCondition(app, status, appcontrollerv1.AppDeploymentAvailable, metav1.ConditionTrue, reasonAvailable,
+		fmt.Sprintf("%d/%d replicas ready", deploy.Status.ReadyReplicas, desired))
+	return true, nil
+}
+
+func (c *appController) setCondition(app *appcontrollerv1.App, status *appcontrollerv1.AppStatus,
+	conditionType string, conditionStatus metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             conditionStatus,
+		ObservedGeneration: app.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+func isDeploymentAvailable(deploy *deployapps.Deployment) bool {
+	for _, cond := range deploy.Status.Conditions {
+		if cond.Type == deployapps.DeploymentAvailable {
+			return cond.Status == core.ConditionTrue
+		}
+	}
+	return false
+}
+
+func loadBalancerAddress(ingress core.LoadBalancerIngress) string {
+	if ingress.Hostname != "" {
+		return ingress.Hostname
+	}
+	return ingress.IP
+}
@@ -23,6 +23,7 @@ import (
 	"net/http"
 
 	appcontrollerv1 "github.com/istudies/k8s-operator/app-controller/pkg/generated/clientset/versioned/typed/appcontroller/v1"
+	appcontrollerv2 "github.com/istudies/k8s-operator/app-controller/pkg/generated/clientset/versioned/typed/appcontroller/v2"
 	discovery "k8s.io/client-go/discovery"
 	rest "k8s.io/client-go/rest"
 	flowcontrol "k8s.io/client-go/util/flowcontrol"
@@ -31,6 +32,7 @@ import (
 type Interface interface {
 	Discovery() discovery.DiscoveryInterface
 	AppcontrollerV1() appcontrollerv1.AppcontrollerV1Interface
+	AppcontrollerV2() appcontrollerv2.AppcontrollerV2Interface
 }
 
 // Clientset contains the clients for groups. Each group has exactly one
@@ -38,6 +40,7 @@ type Interface interface {
 type Clientset struct {
 	*discovery.DiscoveryClient
 	appcontrollerV1 *appcontrollerv1.AppcontrollerV1Client
+	appcontrollerV2 *appcontrollerv2.AppcontrollerV2Client
 }
 
 // AppcontrollerV1 retrieves the AppcontrollerV1Client
@@ -45,6 +48,11 @@ func (c *Clientset) AppcontrollerV1() appcontrollerv1.AppcontrollerV1Interface {
 	return c.appcontrollerV1
 }
 
+// AppcontrollerV2 retrieves the AppcontrollerV2Client
+func (c *Clientset) AppcontrollerV2() appcontrollerv2.AppcontrollerV2Interface {
+	return c.appcontrollerV2
+}
+
 // Discovery retrieves the DiscoveryClient
 func (c *Clientset) Discovery() discovery.DiscoveryInterface {
 	if c == nil {
@@ -89,6 +97,10 @@ func NewForConfigAndClient(c *rest.Config, httpClient *http.Client) (*Clientset,
 	if err != nil {
 		return nil, err
 	}
+	cs.appcontrollerV2, err = appcontrollerv2.NewForConfigAndClient(&configShallowCopy, httpClient)
+	if err != nil {
+		return nil, err
+	}
 
 	cs.DiscoveryClient, err = discovery.NewDiscoveryClientForConfigAndClient(&configShallowCopy, httpClient)
 	if err != nil {
@@ -111,6 +123,7 @@ func NewForConfigOrDie(c *rest.Config) *Clientset {
 func New(c rest.Interface) *Clientset {
 	var cs Clientset
 	cs.appcontrollerV1 = appcontrollerv1.New(c)
+	cs.appcontrollerV2 = appcontrollerv2.New(c)
 
 	cs.DiscoveryClient = discovery.NewDiscoveryClient(c)
 	return &cs
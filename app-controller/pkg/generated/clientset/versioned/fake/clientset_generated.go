@@ -22,6 +22,8 @@ import (
 	clientset "github.com/istudies/k8s-operator/app-controller/pkg/generated/clientset/versioned"
 	appcontrollerv1 "github.com/istudies/k8s-operator/app-controller/pkg/generated/clientset/versioned/typed/appcontroller/v1"
 	fakeappcontrollerv1 "github.com/istudies/k8s-operator/app-controller/pkg/generated/clientset/versioned/typed/appcontroller/v1/fake"
+	appcontrollerv2 "github.com/istudies/k8s-operator/app-controller/pkg/generated/clientset/versioned/typed/appcontroller/v2"
+	fakeappcontrollerv2 "github.com/istudies/k8s-operator/app-controller/pkg/generated/clientset/versioned/typed/appcontroller/v2/fake"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/discovery"
@@ -83,3 +85,8 @@ var (
 func (c *Clientset) AppcontrollerV1() appcontrollerv1.AppcontrollerV1Interface {
 	return &fakeappcontrollerv1.FakeAppcontrollerV1{Fake: &c.Fake}
 }
+
+// AppcontrollerV2 retrieves the AppcontrollerV2Client
+func (c *Clientset) AppcontrollerV2() appcontrollerv2.AppcontrollerV2Interface {
+	return &fakeappcontrollerv2.FakeAppcontrollerV2{Fake: &c.Fake}
+}
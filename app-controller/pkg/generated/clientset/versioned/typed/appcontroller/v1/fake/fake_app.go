@@ -36,9 +36,9 @@ type FakeApps struct {
 	ns   string
 }
 
-var appsResource = schema.GroupVersionResource{Group: "appcontroller", Version: "v1", Resource: "apps"}
+var appsResource = schema.GroupVersionResource{Group: "appcontroller.me", Version: "v1", Resource: "apps"}
 
-var appsKind = schema.GroupVersionKind{Group: "appcontroller", Version: "v1", Kind: "App"}
+var appsKind = schema.GroupVersionKind{Group: "appcontroller.me", Version: "v1", Kind: "App"}
 
 // Get takes name of the app, and returns the corresponding app object, and an error if there is any.
 func (c *FakeApps) Get(ctx context.Context, name string, options v1.GetOptions) (result *appcontrollerv1.App, err error) {
@@ -20,6 +20,7 @@ package appcontroller
 
 import (
 	v1 "github.com/istudies/k8s-operator/app-controller/pkg/generated/informers/externalversions/appcontroller/v1"
+	v2 "github.com/istudies/k8s-operator/app-controller/pkg/generated/informers/externalversions/appcontroller/v2"
 	internalinterfaces "github.com/istudies/k8s-operator/app-controller/pkg/generated/informers/externalversions/internalinterfaces"
 )
 
@@ -27,6 +28,8 @@ import (
 type Interface interface {
 	// V1 provides access to shared informers for resources in V1.
 	V1() v1.Interface
+	// V2 provides access to shared informers for resources in V2.
+	V2() v2.Interface
 }
 
 type group struct {
@@ -44,3 +47,8 @@ func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakList
 func (g *group) V1() v1.Interface {
 	return v1.New(g.factory, g.namespace, g.tweakListOptions)
 }
+
+// V2 returns a new v2.Interface.
+func (g *group) V2() v2.Interface {
+	return v2.New(g.factory, g.namespace, g.tweakListOptions)
+}
@@ -22,6 +22,7 @@ import (
 	"fmt"
 
 	v1 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v1"
+	v2 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v2"
 	schema "k8s.io/apimachinery/pkg/runtime/schema"
 	cache "k8s.io/client-go/tools/cache"
 )
@@ -52,10 +53,14 @@ func (f *genericInformer) Lister() cache.GenericLister {
 // TODO extend this to unknown resources with a client pool
 func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource) (GenericInformer, error) {
 	switch resource {
-	// Group=appcontroller, Version=v1
+	// Group=appcontroller.me, Version=v1
 	case v1.SchemeGroupVersion.WithResource("apps"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Appcontroller().V1().Apps().Informer()}, nil
 
+		// Group=appcontroller.me, Version=v2
+	case v2.SchemeGroupVersion.WithResource("apps"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Appcontroller().V2().Apps().Informer()}, nil
+
 	}
 
 	return nil, fmt.Errorf("no informer found for %v", resource)
@@ -0,0 +1,692 @@
+/*******************************************************************************
+ * @File: app.go
+ * @Description: validation of App specs
+ * @Author: jiangxunyu
+ * @Version: 1.0.0
+ * @Date: 2026/10/18 16:30
+*******************************************************************************/
+
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	appcontrollerv1 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v1"
+	appcontrollerv2 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	net "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// maxClientIPServiceAffinitySeconds mirrors the limit enforced by the api server, one day
+const maxClientIPServiceAffinitySeconds = 86400
+
+// ValidateApp checks that the spec of app is consistent and can be reconciled
+func ValidateApp(app *appcontrollerv1.App) field.ErrorList {
+	specPath := field.NewPath("spec")
+	allErrs := field.ErrorList{}
+	allErrs = append(allErrs, ValidateDeploymentObj(&app.Spec.Deployment, specPath.Child("deployment"))...)
+	allErrs = append(allErrs, ValidateServiceObj(&app.Spec.Service, &app.Spec.Ingress, specPath.Child("service"))...)
+	allErrs = append(allErrs, ValidateIngressObj(&app.Spec.Ingress, &app.Spec.Service, specPath.Child("ingress"))...)
+	allErrs = append(allErrs, ValidateAutoscalingObj(&app.Spec.Autoscaling, specPath.Child("autoscaling"))...)
+	allErrs = append(allErrs, ValidateDisruptionBudgetObj(&app.Spec.DisruptionBudget, specPath.Child("disruptionBudget"))...)
+	allErrs = append(allErrs, ValidateStrategyObj(&app.Spec.Strategy, &app.Spec.Service, &app.Spec.Ingress, specPath.Child("strategy"))...)
+	kind := app.Spec.Deployment.WorkloadKind
+	if kind != "" && kind != appcontrollerv1.DeploymentWorkloadKind {
+		// canary and preview children are deployments next to the stable one
+		switch app.Spec.Strategy.Type {
+		case appcontrollerv1.CanaryStrategyType, appcontrollerv1.BlueGreenStrategyType:
+			allErrs = append(allErrs, field.Invalid(specPath.Child("strategy", "type"), app.Spec.Strategy.Type,
+				"requires the Deployment workload kind"))
+		}
+	}
+	if kind == appcontrollerv1.JobWorkloadKind || kind == appcontrollerv1.CronJobWorkloadKind {
+		// pods running to completion are not scaled on their utilization
+		if app.Spec.Autoscaling.Enabled {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("autoscaling", "enabled"), true,
+				"not supported for the Job and CronJob workload kinds"))
+		}
+	}
+	if kind == appcontrollerv1.StatefulSetWorkloadKind {
+		// the statefulset is governed by a generated headless service
+		if headless := app.Spec.Deployment.Name + "-headless"; app.Spec.Service.Enabled && app.Spec.Service.Name == headless {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("service", "name"), app.Spec.Service.Name,
+				"is taken by the headless service of the statefulset"))
+		}
+	}
+	allErrs = append(allErrs, ValidateComponents(app)...)
+	return allErrs
+}
+
+// ValidateAppCreate checks a new app on admission. On top of ValidateApp it rejects specs the controller
+// tolerates on existing apps but silently ignores, e.g. an enabled ingress without its service.
+func ValidateAppCreate(app *appcontrollerv1.App) field.ErrorList {
+	allErrs := ValidateApp(app)
+	if app.Spec.Ingress.Enabled && !app.Spec.Service.Enabled {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "ingress", "enabled"), true,
+			"the ingress routes to the service and requires `service.enabled`"))
+	}
+	return allErrs
+}
+
+// ValidateComponents checks the components after the first one of an app written as v2. They reach the webhook
+// converted to v1 and kept in an annotation, each is checked like an app of its own.
+func ValidateComponents(app *appcontrollerv1.App) field.ErrorList {
+	allErrs := field.ErrorList{}
+	extra, err := appcontrollerv2.ExtraComponents(app)
+	if err != nil {
+		annotationPath := field.NewPath("metadata", "annotations").Key(appcontrollerv2.ComponentsAnnotation)
+		return append(allErrs, field.Invalid(annotationPath, app.Annotations[appcontrollerv2.ComponentsAnnotation], err.Error()))
+	}
+	componentsPath := field.NewPath("spec", "components")
+	names := map[string]bool{}
+	// children of different components must not share a name
+	children := map[string]bool{
+		"workload/" + app.Spec.Deployment.Name: true,
+		"service/" + app.Spec.Service.Name:     app.Spec.Service.Enabled,
+		"ingress/" + app.Spec.Ingress.Name:     app.Spec.Service.Enabled && app.Spec.Ingress.Enabled,
+	}
+	for i, component := range extra {
+		fldPath := componentsPath.Index(i + 1)
+		for _, msg := range validation.IsDNS1123Label(component.Name) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("name"), component.Name, msg))
+		}
+		switch {
+		case names[component.Name]:
+			allErrs = append(allErrs, field.Duplicate(fldPath.Child("name"), component.Name))
+		case component.Name == "canary" || component.Name == "preview":
+			// the pods of the component would be selected together with the rollout pods of the first one
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("name"), component.Name, "is reserved for the rollout children"))
+		}
+		names[component.Name] = true
+
+		// the rollout state of an app is that of its first component
+		switch component.Strategy.Type {
+		case appcontrollerv2.CanaryStrategyType, appcontrollerv2.BlueGreenStrategyType:
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("strategy", "type"), component.Strategy.Type,
+				"only supported for the first component"))
+		}
+
+		componentApp := appcontrollerv2.ComponentApp(app, component)
+		appcontrollerv1.SetDefaults_App(componentApp)
+		for _, e := range ValidateApp(componentApp) {
+			// the deployment of v1 is the workload of v2
+			e.Field = fldPath.String() + "." + strings.Replace(strings.TrimPrefix(e.Field, "spec."), "deployment", "workload", 1)
+			allErrs = append(allErrs, e)
+		}
+		spec := componentApp.Spec
+		for _, child := range []struct {
+			key     string
+			path    *field.Path
+			name    string
+			enabled bool
+		}{
+			{"workload/", fldPath.Child("workload", "name"), spec.Deployment.Name, true},
+			{"service/", fldPath.Child("service", "name"), spec.Service.Name, spec.Service.Enabled},
+			{"ingress/", fldPath.Child("ingress", "name"), spec.Ingress.Name, spec.Service.Enabled && spec.Ingress.Enabled},
+		} {
+			if !child.enabled {
+				continue
+			}
+			if children[child.key+child.name] {
+				allErrs = append(allErrs, field.Duplicate(child.path, child.name))
+			}
+			children[child.key+child.name] = true
+		}
+	}
+	return allErrs
+}
+
+// ValidateAppUpdate checks an updated app on admission, the same way as a new one
+func ValidateAppUpdate(app, old *appcontrollerv1.App) field.ErrorList {
+	return ValidateAppCreate(app)
+}
+
+// ValidateChildNamesUnchanged forbids renaming the children of an app. Renames are otherwise supported,
+// the children left behind are pruned.
+func ValidateChildNamesUnchanged(app, old *appcontrollerv1.App) field.ErrorList {
+	specPath := field.NewPath("spec")
+	allErrs := field.ErrorList{}
+	if app.Spec.Deployment.Name != old.Spec.Deployment.Name {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("deployment", "name"), "field is immutable"))
+	}
+	// a disabled child has no name to keep
+	if app.Spec.Service.Enabled && old.Spec.Service.Enabled && app.Spec.Service.Name != old.Spec.Service.Name {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("service", "name"), "field is immutable"))
+	}
+	if app.Spec.Ingress.Enabled && old.Spec.Ingress.Enabled && app.Spec.Ingress.Name != old.Spec.Ingress.Name {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("ingress", "name"), "field is immutable"))
+	}
+	return allErrs
+}
+
+// validateChildName checks the name of a child object, which must be a DNS subdomain
+func validateChildName(name string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if name == "" {
+		return append(allErrs, field.Required(fldPath, ""))
+	}
+	for _, msg := range validation.IsDNS1123Subdomain(name) {
+		allErrs = append(allErrs, field.Invalid(fldPath, name, msg))
+	}
+	return allErrs
+}
+
+// ValidateDeploymentObj checks the workload kind, the container options, the referenced configuration and that
+// every volume mount refers to a declared volume or volume claim template
+func ValidateDeploymentObj(deploy *appcontrollerv1.DeploymentObj, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	allErrs = append(allErrs, validateChildName(deploy.Name, fldPath.Child("name"))...)
+	if strings.TrimSpace(deploy.Image) == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("image"), ""))
+	}
+	if deploy.Replicas < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("replicas"), deploy.Replicas, "must be greater than or equal to 0"))
+	}
+
+	switch deploy.WorkloadKind {
+	case "", appcontrollerv1.DeploymentWorkloadKind, appcontrollerv1.StatefulSetWorkloadKind,
+		appcontrollerv1.JobWorkloadKind, appcontrollerv1.CronJobWorkloadKind:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("workloadKind"), deploy.WorkloadKind, []string{
+			string(appcontrollerv1.DeploymentWorkloadKind),
+			string(appcontrollerv1.StatefulSetWorkloadKind),
+			string(appcontrollerv1.JobWorkloadKind),
+			string(appcontrollerv1.CronJobWorkloadKind),
+		}))
+	}
+	allErrs = append(allErrs, ValidateBatchObj(deploy.Batch, deploy.WorkloadKind, fldPath.Child("batch"))...)
+
+	switch deploy.ImagePullPolicy {
+	case "", core.PullAlways, core.PullIfNotPresent, core.PullNever:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("imagePullPolicy"), deploy.ImagePullPolicy,
+			[]string{string(core.PullAlways), string(core.PullIfNotPresent), string(core.PullNever)}))
+	}
+
+	envPath := fldPath.Child("env")
+	for i, env := range deploy.Env {
+		idxPath := envPath.Index(i)
+		if env.Name == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("name"), ""))
+		} else {
+			for _, msg := range validation.IsEnvVarName(env.Name) {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), env.Name, msg))
+			}
+		}
+		if env.ValueFrom != nil {
+			allErrs = append(allErrs, validateEnvVarSource(env.ValueFrom, idxPath.Child("valueFrom"))...)
+			if env.Value != "" {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("valueFrom"), "", "may not be specified when `value` is not empty"))
+			}
+		}
+	}
+
+	for i, name := range deploy.ConfigMaps {
+		for _, msg := range validation.IsDNS1123Subdomain(name) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("configMaps").Index(i), name, msg))
+		}
+	}
+	for i, name := range deploy.Secrets {
+		for _, msg := range validation.IsDNS1123Subdomain(name) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("secrets").Index(i), name, msg))
+		}
+	}
+
+	volumesPath := fldPath.Child("volumes")
+	volumes := map[string]bool{}
+	for i, volume := range deploy.Volumes {
+		idxPath := volumesPath.Index(i)
+		for _, msg := range validation.IsDNS1123Label(volume.Name) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), volume.Name, msg))
+		}
+		if volumes[volume.Name] {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), volume.Name))
+		}
+		volumes[volume.Name] = true
+	}
+
+	// claims are mounted like volumes, by name
+	claimsPath := fldPath.Child("volumeClaimTemplates")
+	if len(deploy.VolumeClaimTemplates) > 0 && deploy.WorkloadKind != appcontrollerv1.StatefulSetWorkloadKind {
+		allErrs = append(allErrs, field.Forbidden(claimsPath, "only supported for the StatefulSet workload kind"))
+	}
+	for i, claim := range deploy.VolumeClaimTemplates {
+		idxPath := claimsPath.Index(i)
+		for _, msg := range validation.IsDNS1123Label(claim.Name) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("metadata", "name"), claim.Name, msg))
+		}
+		if volumes[claim.Name] {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("metadata", "name"), claim.Name))
+		}
+		volumes[claim.Name] = true
+		if len(claim.Spec.AccessModes) == 0 {
+			allErrs = append(allErrs, field.Required(idxPath.Child("spec", "accessModes"), ""))
+		}
+		if _, ok := claim.Spec.Resources.Requests[core.ResourceStorage]; !ok {
+			allErrs = append(allErrs, field.Required(idxPath.Child("spec", "resources", "requests", "storage"), ""))
+		}
+	}
+
+	mountsPath := fldPath.Child("volumeMounts")
+	mountPaths := map[string]bool{}
+	for i, mount := range deploy.VolumeMounts {
+		idxPath := mountsPath.Index(i)
+		if !volumes[mount.Name] {
+			allErrs = append(allErrs, field.NotFound(idxPath.Child("name"), mount.Name))
+		}
+		if mount.MountPath == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("mountPath"), ""))
+		} else if mountPaths[mount.MountPath] {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("mountPath"), mount.MountPath, "must be unique"))
+		}
+		mountPaths[mount.MountPath] = true
+	}
+	return allErrs
+}
+
+// ValidateBatchObj checks the job options, which only the Job and CronJob workload kinds take.
+// The schedule is required for a CronJob and only checked for its shape, the api server parses it.
+func ValidateBatchObj(batch *appcontrollerv1.BatchObj, kind appcontrollerv1.WorkloadKind, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if kind != appcontrollerv1.JobWorkloadKind && kind != appcontrollerv1.CronJobWorkloadKind {
+		if batch != nil {
+			allErrs = append(allErrs, field.Forbidden(fldPath, "only supported for the Job and CronJob workload kinds"))
+		}
+		return allErrs
+	}
+	if batch == nil {
+		batch = &appcontrollerv1.BatchObj{}
+	}
+
+	schedulePath := fldPath.Child("schedule")
+	switch {
+	case kind != appcontrollerv1.CronJobWorkloadKind:
+		if batch.Schedule != "" {
+			allErrs = append(allErrs, field.Forbidden(schedulePath, "only supported for the CronJob workload kind"))
+		}
+	case batch.Schedule == "":
+		allErrs = append(allErrs, field.Required(schedulePath, "required for the CronJob workload kind"))
+	case !strings.HasPrefix(batch.Schedule, "@") && len(strings.Fields(batch.Schedule)) != 5:
+		allErrs = append(allErrs, field.Invalid(schedulePath, batch.Schedule,
+			"must have 5 fields: minute, hour, day of month, month and day of week, or be a macro like @hourly"))
+	}
+
+	switch batch.ConcurrencyPolicy {
+	case "", batchv1.AllowConcurrent, batchv1.ForbidConcurrent, batchv1.ReplaceConcurrent:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("concurrencyPolicy"), batch.ConcurrencyPolicy,
+			[]string{string(batchv1.AllowConcurrent), string(batchv1.ForbidConcurrent), string(batchv1.ReplaceConcurrent)}))
+	}
+	for name, limit := range map[string]*int32{
+		"backoffLimit":               batch.BackoffLimit,
+		"successfulJobsHistoryLimit": batch.SuccessfulJobsHistoryLimit,
+		"failedJobsHistoryLimit":     batch.FailedJobsHistoryLimit,
+	} {
+		if limit != nil && *limit < 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child(name), *limit, "must be greater than or equal to 0"))
+		}
+	}
+	return allErrs
+}
+
+// validateEnvVarSource checks that exactly one source is set
+func validateEnvVarSource(source *core.EnvVarSource, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	sources := 0
+	if source.FieldRef != nil {
+		sources++
+	}
+	if source.ResourceFieldRef != nil {
+		sources++
+	}
+	if source.ConfigMapKeyRef != nil {
+		sources++
+		if source.ConfigMapKeyRef.Name == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("configMapKeyRef", "name"), ""))
+		}
+		if source.ConfigMapKeyRef.Key == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("configMapKeyRef", "key"), ""))
+		}
+	}
+	if source.SecretKeyRef != nil {
+		sources++
+		if source.SecretKeyRef.Name == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("secretKeyRef", "name"), ""))
+		}
+		if source.SecretKeyRef.Key == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("secretKeyRef", "key"), ""))
+		}
+	}
+	switch {
+	case sources == 0:
+		allErrs = append(allErrs, field.Invalid(fldPath, "", "must specify one of: `fieldRef`, `resourceFieldRef`, `configMapKeyRef` or `secretKeyRef`"))
+	case sources > 1:
+		allErrs = append(allErrs, field.Invalid(fldPath, "", "may not have more than one field specified at a time"))
+	}
+	return allErrs
+}
+
+// ValidateServiceObj checks service type options, ports, and that an enabled ingress has a port to route to
+func ValidateServiceObj(svc *appcontrollerv1.ServiceObj, ingress *appcontrollerv1.IngressObj, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if svc.Enabled {
+		allErrs = append(allErrs, validateChildName(svc.Name, fldPath.Child("name"))...)
+	}
+
+	svcType := svc.Type
+	if svcType == "" {
+		svcType = core.ServiceTypeClusterIP
+	}
+	switch svcType {
+	case core.ServiceTypeClusterIP, core.ServiceTypeNodePort, core.ServiceTypeLoadBalancer:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("type"), svc.Type,
+			[]string{string(core.ServiceTypeClusterIP), string(core.ServiceTypeNodePort), string(core.ServiceTypeLoadBalancer)}))
+	}
+	if svc.Headless && svcType != core.ServiceTypeClusterIP {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("headless"), svc.Headless, "only ClusterIP services can be headless"))
+	}
+	switch svc.SessionAffinity {
+	case "", core.ServiceAffinityNone, core.ServiceAffinityClientIP:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("sessionAffinity"), svc.SessionAffinity,
+			[]string{string(core.ServiceAffinityNone), string(core.ServiceAffinityClientIP)}))
+	}
+	if svc.SessionAffinityTimeoutSeconds != nil {
+		timeoutPath := fldPath.Child("sessionAffinityTimeoutSeconds")
+		if svc.SessionAffinity != core.ServiceAffinityClientIP {
+			allErrs = append(allErrs, field.Forbidden(timeoutPath, "only allowed with ClientIP session affinity"))
+		} else if timeout := *svc.SessionAffinityTimeoutSeconds; timeout <= 0 || timeout > maxClientIPServiceAffinitySeconds {
+			allErrs = append(allErrs, field.Invalid(timeoutPath, timeout,
+				fmt.Sprintf("must be greater than 0 and less than or equal to %d", maxClientIPServiceAffinitySeconds)))
+		}
+	}
+
+	portsPath := fldPath.Child("ports")
+	names := map[string]bool{}
+	ports := map[string]bool{}
+	hasTCP := len(svc.Ports) == 0
+	for i, port := range svc.Ports {
+		idxPath := portsPath.Index(i)
+		if len(svc.Ports) > 1 && port.Name == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("name"), "must be set when more than one port is declared"))
+		}
+		if port.Name != "" {
+			for _, msg := range validation.IsValidPortName(port.Name) {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), port.Name, msg))
+			}
+			if names[port.Name] {
+				allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), port.Name))
+			}
+			names[port.Name] = true
+		}
+
+		protocol := port.Protocol
+		if protocol == "" {
+			protocol = core.ProtocolTCP
+		}
+		switch protocol {
+		case core.ProtocolTCP:
+			hasTCP = true
+		case core.ProtocolUDP, core.ProtocolSCTP:
+		default:
+			allErrs = append(allErrs, field.NotSupported(idxPath.Child("protocol"), port.Protocol,
+				[]string{string(core.ProtocolTCP), string(core.ProtocolUDP), string(core.ProtocolSCTP)}))
+		}
+
+		for _, msg := range validation.IsValidPortNum(int(port.Port)) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("port"), port.Port, msg))
+		}
+		key := fmt.Sprintf("%d/%s", port.Port, protocol)
+		if ports[key] {
+			allErrs = append(allErrs, field.Duplicate(idxPath, key))
+		}
+		ports[key] = true
+
+		if port.TargetPort != 0 {
+			for _, msg := range validation.IsValidPortNum(int(port.TargetPort)) {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("targetPort"), port.TargetPort, msg))
+			}
+		}
+		if port.NodePort != 0 {
+			if svcType != core.ServiceTypeNodePort && svcType != core.ServiceTypeLoadBalancer {
+				allErrs = append(allErrs, field.Forbidden(idxPath.Child("nodePort"), "only allowed for NodePort and LoadBalancer services"))
+			}
+			for _, msg := range validation.IsValidPortNum(int(port.NodePort)) {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("nodePort"), port.NodePort, msg))
+			}
+		}
+	}
+
+	if svc.Enabled && ingress.Enabled && !hasTCP {
+		allErrs = append(allErrs, field.Invalid(portsPath, svc.Ports, "ingress needs at least one TCP port to route to"))
+	}
+	return allErrs
+}
+
+// ValidateIngressObj checks host rules, paths, backends and tls blocks of the app ingress
+func ValidateIngressObj(ingress *appcontrollerv1.IngressObj, svc *appcontrollerv1.ServiceObj, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if ingress.Enabled {
+		allErrs = append(allErrs, validateChildName(ingress.Name, fldPath.Child("name"))...)
+	}
+
+	if ingress.ClassName != nil && *ingress.ClassName != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(*ingress.ClassName) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("className"), *ingress.ClassName, msg))
+		}
+	}
+
+	rulesPath := fldPath.Child("rules")
+	for i, rule := range ingress.Rules {
+		rulePath := rulesPath.Index(i)
+		if rule.Host != "" {
+			allErrs = append(allErrs, validateHost(rule.Host, rulePath.Child("host"))...)
+		}
+		pathsPath := rulePath.Child("paths")
+		for j, path := range rule.Paths {
+			allErrs = append(allErrs, validateIngressPath(&path, svc, pathsPath.Index(j))...)
+		}
+	}
+
+	tlsPath := fldPath.Child("tls")
+	for i, tls := range ingress.TLS {
+		for j, host := range tls.Hosts {
+			allErrs = append(allErrs, validateHost(host, tlsPath.Index(i).Child("hosts").Index(j))...)
+		}
+		if tls.SecretName != "" {
+			for _, msg := range validation.IsDNS1123Subdomain(tls.SecretName) {
+				allErrs = append(allErrs, field.Invalid(tlsPath.Index(i).Child("secretName"), tls.SecretName, msg))
+			}
+		}
+	}
+	return allErrs
+}
+
+func validateHost(host string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	var msgs []string
+	if strings.HasPrefix(host, "*.") {
+		msgs = validation.IsWildcardDNS1123Subdomain(host)
+	} else {
+		msgs = validation.IsDNS1123Subdomain(host)
+	}
+	for _, msg := range msgs {
+		allErrs = append(allErrs, field.Invalid(fldPath, host, msg))
+	}
+	return allErrs
+}
+
+func validateIngressPath(path *appcontrollerv1.IngressPath, svc *appcontrollerv1.ServiceObj, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	pathType := path.PathType
+	if pathType == "" {
+		pathType = net.PathTypePrefix
+	}
+	switch pathType {
+	case net.PathTypePrefix, net.PathTypeExact:
+		if path.Path != "" && !strings.HasPrefix(path.Path, "/") {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("path"), path.Path, "must be an absolute path"))
+		}
+	case net.PathTypeImplementationSpecific:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("pathType"), path.PathType,
+			[]string{string(net.PathTypePrefix), string(net.PathTypeExact), string(net.PathTypeImplementationSpecific)}))
+	}
+
+	if path.ServiceName != "" {
+		for _, msg := range validation.IsDNS1035Label(path.ServiceName) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("serviceName"), path.ServiceName, msg))
+		}
+		if path.ServicePort == nil {
+			allErrs = append(allErrs, field.Required(fldPath.Child("servicePort"), "must be set for a service other than the app service"))
+		}
+		return allErrs
+	}
+	// the backend is the app service, the port has to be one of its declared ports
+	if path.ServicePort != nil && !hasServicePort(svc, *path.ServicePort) {
+		allErrs = append(allErrs, field.NotFound(fldPath.Child("servicePort"), path.ServicePort.String()))
+	}
+	return allErrs
+}
+
+func hasServicePort(svc *appcontrollerv1.ServiceObj, port intstr.IntOrString) bool {
+	if len(svc.Ports) == 0 {
+		return port.Type == intstr.Int && port.IntVal == 80
+	}
+	for _, p := range svc.Ports {
+		if p.Protocol != "" && p.Protocol != core.ProtocolTCP {
+			continue
+		}
+		if port.Type == intstr.String && p.Name == port.StrVal {
+			return true
+		}
+		if port.Type == intstr.Int && p.Port == port.IntVal {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateAutoscalingObj checks the replica bounds and utilization targets of an enabled autoscaler
+func ValidateAutoscalingObj(autoscaling *appcontrollerv1.AutoscalingObj, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if !autoscaling.Enabled {
+		return allErrs
+	}
+
+	minReplicas := int32(1)
+	if autoscaling.MinReplicas != nil {
+		minReplicas = *autoscaling.MinReplicas
+		if minReplicas < 1 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("minReplicas"), minReplicas, "must be greater than or equal to 1"))
+		}
+	}
+	if autoscaling.MaxReplicas < 1 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("maxReplicas"), "must be greater than or equal to 1"))
+	} else if autoscaling.MaxReplicas < minReplicas {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("maxReplicas"), autoscaling.MaxReplicas, "must be greater than or equal to `minReplicas`"))
+	}
+	if target := autoscaling.TargetCPUUtilizationPercentage; target != nil && *target < 1 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("targetCPUUtilizationPercentage"), *target, "must be greater than 0"))
+	}
+	if target := autoscaling.TargetMemoryUtilizationPercentage; target != nil && *target < 1 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("targetMemoryUtilizationPercentage"), *target, "must be greater than 0"))
+	}
+	metricsPath := fldPath.Child("metrics")
+	for i, metric := range autoscaling.Metrics {
+		if metric.Type == "" {
+			allErrs = append(allErrs, field.Required(metricsPath.Index(i).Child("type"), ""))
+		}
+	}
+	return allErrs
+}
+
+// ValidateDisruptionBudgetObj checks that an enabled disruption budget sets at most one of its limits
+func ValidateDisruptionBudgetObj(budget *appcontrollerv1.DisruptionBudgetObj, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if !budget.Enabled {
+		return allErrs
+	}
+	if budget.MinAvailable != nil && budget.MaxUnavailable != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("maxUnavailable"), budget.MaxUnavailable.String(), "may not be set together with `minAvailable`"))
+	}
+	if budget.MinAvailable != nil {
+		allErrs = append(allErrs, validateIntOrPercent(*budget.MinAvailable, fldPath.Child("minAvailable"))...)
+	}
+	if budget.MaxUnavailable != nil {
+		allErrs = append(allErrs, validateIntOrPercent(*budget.MaxUnavailable, fldPath.Child("maxUnavailable"))...)
+	}
+	return allErrs
+}
+
+// validateIntOrPercent checks a non negative number or a percentage between 0% and 100%
+func validateIntOrPercent(value intstr.IntOrString, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if value.Type == intstr.Int {
+		if value.IntVal < 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath, value.IntVal, "must be greater than or equal to 0"))
+		}
+		return allErrs
+	}
+	var percent int
+	if _, err := fmt.Sscanf(value.StrVal, "%d%%", &percent); err != nil || fmt.Sprintf("%d%%", percent) != value.StrVal {
+		return append(allErrs, field.Invalid(fldPath, value.StrVal, "must be an integer or a percentage, e.g. 1 or 50%"))
+	}
+	if percent < 0 || percent > 100 {
+		allErrs = append(allErrs, field.Invalid(fldPath, value.StrVal, "must be between 0% and 100%"))
+	}
+	return allErrs
+}
+
+// ValidateStrategyObj checks the rollout strategy and the children it needs to route traffic to a new image
+func ValidateStrategyObj(strategy *appcontrollerv1.StrategyObj, svc *appcontrollerv1.ServiceObj, ingress *appcontrollerv1.IngressObj,
+	fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	switch strategy.Type {
+	case "", appcontrollerv1.RollingUpdateStrategyType:
+	case appcontrollerv1.CanaryStrategyType:
+		// the canary only receives traffic through its weighted ingress
+		if !svc.Enabled || !ingress.Enabled {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("type"), strategy.Type, "requires `service.enabled` and `ingress.enabled`"))
+		}
+		if strategy.Canary == nil || len(strategy.Canary.Steps) == 0 {
+			allErrs = append(allErrs, field.Required(fldPath.Child("canary", "steps"), "at least one step is required"))
+			break
+		}
+		canaryPath := fldPath.Child("canary")
+		if replicas := strategy.Canary.Replicas; replicas != nil && *replicas < 1 {
+			allErrs = append(allErrs, field.Invalid(canaryPath.Child("replicas"), *replicas, "must be greater than or equal to 1"))
+		}
+		for i, step := range strategy.Canary.Steps {
+			idxPath := canaryPath.Child("steps").Index(i)
+			if step.Weight < 0 || step.Weight > 100 {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("weight"), step.Weight, "must be between 0 and 100"))
+			}
+			if step.Pause != nil && step.Pause.Duration < 0 {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("pause"), step.Pause.Duration.String(), "must not be negative"))
+			}
+		}
+	case appcontrollerv1.BlueGreenStrategyType:
+		// promotion switches the service selector
+		if !svc.Enabled {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("type"), strategy.Type, "requires `service.enabled`"))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("type"), strategy.Type, []string{
+			string(appcontrollerv1.RollingUpdateStrategyType),
+			string(appcontrollerv1.CanaryStrategyType),
+			string(appcontrollerv1.BlueGreenStrategyType),
+		}))
+	}
+	return allErrs
+}
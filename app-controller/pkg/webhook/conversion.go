@@ -0,0 +1,121 @@
+/*******************************************************************************
+ * @File: conversion.go
+ * @Description: conversion webhook of App resources between v1 and v2
+ * @Author: jiangxunyu
+ * @Version: 1.0.0
+ * @Date: 2026/10/20 10:30
+*******************************************************************************/
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	appcontrollerv1 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v1"
+	appcontrollerv2 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// conversionReview mirrors the apiextensions.k8s.io/v1 ConversionReview the api server posts,
+// the rest of the apiextensions api is not needed by the webhook
+type conversionReview struct {
+	metav1.TypeMeta `json:",inline"`
+	Request         *conversionRequest  `json:"request,omitempty"`
+	Response        *conversionResponse `json:"response,omitempty"`
+}
+
+type conversionRequest struct {
+	UID               types.UID              `json:"uid"`
+	DesiredAPIVersion string                 `json:"desiredAPIVersion"`
+	Objects           []runtime.RawExtension `json:"objects"`
+}
+
+type conversionResponse struct {
+	UID              types.UID              `json:"uid"`
+	ConvertedObjects []runtime.RawExtension `json:"convertedObjects"`
+	Result           metav1.Status          `json:"result"`
+}
+
+type appConverter struct{}
+
+// NewConversionHandler returns the handler converting Apps between v1 and v2 through the v1 hub
+func NewConversionHandler() http.Handler {
+	return &appConverter{}
+}
+
+func (c *appConverter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request conversionReview
+	if !readReview(w, r, &request) {
+		return
+	}
+	if request.Request == nil {
+		http.Error(w, "conversion review without request", http.StatusBadRequest)
+		return
+	}
+	writeReview(w, conversionReview{TypeMeta: request.TypeMeta, Response: c.convert(request.Request)})
+}
+
+// convert converts every object of req to the desired version, failing the whole request on the first error
+func (c *appConverter) convert(req *conversionRequest) *conversionResponse {
+	response := &conversionResponse{UID: req.UID}
+	for _, object := range req.Objects {
+		converted, err := convertApp(object.Raw, req.DesiredAPIVersion)
+		if err != nil {
+			fmt.Printf("convert app to %s: %v\n", req.DesiredAPIVersion, err)
+			response.ConvertedObjects = nil
+			response.Result = metav1.Status{Status: metav1.StatusFailure, Message: err.Error()}
+			return response
+		}
+		response.ConvertedObjects = append(response.ConvertedObjects, runtime.RawExtension{Raw: converted})
+	}
+	response.Result = metav1.Status{Status: metav1.StatusSuccess}
+	return response
+}
+
+func convertApp(raw []byte, desiredAPIVersion string) ([]byte, error) {
+	var typeMeta metav1.TypeMeta
+	if err := json.Unmarshal(raw, &typeMeta); err != nil {
+		return nil, fmt.Errorf("decode object: %w", err)
+	}
+	if typeMeta.Kind != "App" {
+		return nil, fmt.Errorf("unexpected kind %s, only App is converted", typeMeta.Kind)
+	}
+	if typeMeta.APIVersion == desiredAPIVersion {
+		return raw, nil
+	}
+
+	v1Version := appcontrollerv1.SchemeGroupVersion.String()
+	v2Version := appcontrollerv2.SchemeGroupVersion.String()
+	var converted runtime.Object
+	switch {
+	case typeMeta.APIVersion == v1Version && desiredAPIVersion == v2Version:
+		src := &appcontrollerv1.App{}
+		if err := json.Unmarshal(raw, src); err != nil {
+			return nil, fmt.Errorf("decode app: %w", err)
+		}
+		dst := &appcontrollerv2.App{}
+		if err := dst.ConvertFrom(src); err != nil {
+			return nil, err
+		}
+		converted = dst
+	case typeMeta.APIVersion == v2Version && desiredAPIVersion == v1Version:
+		src := &appcontrollerv2.App{}
+		if err := json.Unmarshal(raw, src); err != nil {
+			return nil, fmt.Errorf("decode app: %w", err)
+		}
+		dst := &appcontrollerv1.App{}
+		if err := src.ConvertTo(dst); err != nil {
+			return nil, err
+		}
+		converted = dst
+	default:
+		return nil, fmt.Errorf("unsupported conversion from %s to %s", typeMeta.APIVersion, desiredAPIVersion)
+	}
+	converted.GetObjectKind().SetGroupVersionKind(schema.FromAPIVersionAndKind(desiredAPIVersion, "App"))
+	return json.Marshal(converted)
+}
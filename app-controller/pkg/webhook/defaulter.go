@@ -0,0 +1,58 @@
+/*******************************************************************************
+ * @File: defaulter.go
+ * @Description: mutating admission webhook defaulting App resources
+ * @Author: jiangxunyu
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 18:20
+*******************************************************************************/
+
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+
+	appcontrollerv1 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v1"
+	admission "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+type appDefaulter struct{}
+
+// NewMutatingHandler returns the handler filling the defaults of created and updated Apps
+func NewMutatingHandler() http.Handler {
+	return &appDefaulter{}
+}
+
+func (d *appDefaulter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveReview(w, r, d.review)
+}
+
+// review answers with a json patch replacing the spec of the app of req by its defaulted spec
+func (d *appDefaulter) review(req *admission.AdmissionRequest) *admission.AdmissionResponse {
+	if err := checkKind(req); err != nil {
+		return deny(err)
+	}
+	if req.Operation != admission.Create && req.Operation != admission.Update || req.SubResource != "" {
+		return allow()
+	}
+	app, err := decodeApp(req.Object.Raw)
+	if err != nil {
+		return deny(err)
+	}
+	defaulted := app.DeepCopy()
+	appcontrollerv1.SetDefaults_App(defaulted)
+	if equality.Semantic.DeepEqual(app.Spec, defaulted.Spec) {
+		return allow()
+	}
+
+	patch, marshalErr := json.Marshal([]map[string]interface{}{
+		{"op": "replace", "path": "/spec", "value": defaulted.Spec},
+	})
+	if marshalErr != nil {
+		return deny(errors.NewInternalError(marshalErr))
+	}
+	patchType := admission.PatchTypeJSONPatch
+	return &admission.AdmissionResponse{Allowed: true, Patch: patch, PatchType: &patchType}
+}
@@ -0,0 +1,192 @@
+/*******************************************************************************
+ * @File: webhook.go
+ * @Description: validating admission webhook of App resources
+ * @Author: jiangxunyu
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 16:10
+*******************************************************************************/
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+
+	appcontrollerv1 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v1"
+	"github.com/istudies/k8s-operator/app-controller/pkg/validation"
+	admission "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// paths the webhook configurations and the App CRD call
+const (
+	ValidatePath = "/validate-appcontroller-me-v1-app"
+	MutatePath   = "/mutate-appcontroller-me-v1-app"
+	ConvertPath  = "/convert"
+)
+
+// DefaultCertDir holds tls.crt and tls.key, where the serving certificate secret is mounted
+const DefaultCertDir = "/tmp/k8s-webhook-server/serving-certs"
+
+// maxRequestBytes bounds the admission reviews read, the api server sends at most a few megabytes
+const maxRequestBytes = 7 * 1024 * 1024
+
+// Option configures the app validator
+type Option func(*appValidator)
+
+// WithImmutableChildNames rejects updates renaming the deployment, service or ingress of an app
+func WithImmutableChildNames(immutable bool) Option {
+	return func(v *appValidator) {
+		v.immutableChildNames = immutable
+	}
+}
+
+type appValidator struct {
+	immutableChildNames bool
+}
+
+// NewValidatingHandler returns the handler answering the admission reviews of Apps
+func NewValidatingHandler(opts ...Option) http.Handler {
+	v := &appValidator{}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Serve serves validator on ValidatePath, defaulter on MutatePath and converter on ConvertPath over TLS,
+// with the certificate and key found in certDir
+func Serve(addr, certDir string, validator, defaulter, converter http.Handler) error {
+	mux := http.NewServeMux()
+	mux.Handle(ValidatePath, validator)
+	mux.Handle(MutatePath, defaulter)
+	mux.Handle(ConvertPath, converter)
+	return http.ListenAndServeTLS(addr, filepath.Join(certDir, "tls.crt"), filepath.Join(certDir, "tls.key"), mux)
+}
+
+func (v *appValidator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveReview(w, r, v.review)
+}
+
+// serveReview decodes the admission review posted to r and answers it with the response of review
+func serveReview(w http.ResponseWriter, r *http.Request, review func(*admission.AdmissionRequest) *admission.AdmissionResponse) {
+	var request admission.AdmissionReview
+	if !readReview(w, r, &request) {
+		return
+	}
+	if request.Request == nil {
+		http.Error(w, "admission review without request", http.StatusBadRequest)
+		return
+	}
+
+	response := review(request.Request)
+	response.UID = request.Request.UID
+	// answer with the version of the request, the api server rejects any other
+	writeReview(w, admission.AdmissionReview{TypeMeta: request.TypeMeta, Response: response})
+}
+
+// readReview decodes the review posted to r into review, answering the malformed ones itself
+func readReview(w http.ResponseWriter, r *http.Request, review interface{}) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if err := json.Unmarshal(body, review); err != nil {
+		http.Error(w, fmt.Sprintf("decode review: %v", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeReview(w http.ResponseWriter, review interface{}) {
+	data, err := json.Marshal(review)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+// review admits or denies the App of req
+func (v *appValidator) review(req *admission.AdmissionRequest) *admission.AdmissionResponse {
+	if err := checkKind(req); err != nil {
+		return deny(err)
+	}
+
+	var errs field.ErrorList
+	switch req.Operation {
+	case admission.Create:
+		app, err := decodeApp(req.Object.Raw)
+		if err != nil {
+			return deny(err)
+		}
+		errs = validation.ValidateAppCreate(app)
+	case admission.Update:
+		// status updates of the controller do not touch the spec
+		if req.SubResource != "" {
+			return allow()
+		}
+		app, err := decodeApp(req.Object.Raw)
+		if err != nil {
+			return deny(err)
+		}
+		old, err := decodeApp(req.OldObject.Raw)
+		if err != nil {
+			return deny(err)
+		}
+		// metadata updates, e.g. the finalizer released on deletion, must go through even for apps admitted
+		// before the webhook existed
+		if equality.Semantic.DeepEqual(app.Spec, old.Spec) {
+			return allow()
+		}
+		errs = validation.ValidateAppUpdate(app, old)
+		if v.immutableChildNames {
+			errs = append(errs, validation.ValidateChildNamesUnchanged(app, old)...)
+		}
+	default:
+		return allow()
+	}
+	if len(errs) > 0 {
+		fmt.Printf("deny %s of app %s/%s: %v\n", req.Operation, req.Namespace, req.Name, errs.ToAggregate())
+		return deny(errors.NewInvalid(appGroupKind, req.Name, errs))
+	}
+	return allow()
+}
+
+// appGroupKind is the only kind the webhooks are registered for
+var appGroupKind = appcontrollerv1.Kind("App")
+
+func checkKind(req *admission.AdmissionRequest) *errors.StatusError {
+	if req.Kind.Group != appGroupKind.Group || req.Kind.Kind != appGroupKind.Kind {
+		return errors.NewBadRequest(fmt.Sprintf("unexpected kind %s, only %s is admitted", req.Kind, appGroupKind))
+	}
+	return nil
+}
+
+func decodeApp(raw []byte) (*appcontrollerv1.App, *errors.StatusError) {
+	app := &appcontrollerv1.App{}
+	if err := json.Unmarshal(raw, app); err != nil {
+		return nil, errors.NewBadRequest(fmt.Sprintf("decode app: %v", err))
+	}
+	return app, nil
+}
+
+func allow() *admission.AdmissionResponse {
+	return &admission.AdmissionResponse{Allowed: true}
+}
+
+func deny(err errors.APIStatus) *admission.AdmissionResponse {
+	status := err.Status()
+	return &admission.AdmissionResponse{Allowed: false, Result: &status}
+}
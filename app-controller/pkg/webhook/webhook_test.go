@@ -0,0 +1,259 @@
+/*******************************************************************************
+ * @File: webhook_test.go
+ * @Description: admission and conversion review fixture tests of the app webhooks
+ * @Author: jiangxunyu
+ * @Version: 1.0.0
+ * @Date: 2026/10/19 16:40
+*******************************************************************************/
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	appcontrollerv1 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v1"
+	appcontrollerv2 "github.com/istudies/k8s-operator/app-controller/pkg/apis/appcontroller/v2"
+	admission "k8s.io/api/admission/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// review posts the admission review fixture in testdata to handler and returns its answer
+func review(t *testing.T, handler http.Handler, fixture string) *admission.AdmissionReview {
+	data, err := os.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	var request admission.AdmissionReview
+	if err := json.Unmarshal(data, &request); err != nil {
+		t.Fatalf("decode fixture: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, ValidatePath, bytes.NewReader(data)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var response admission.AdmissionReview
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode admission review: %v", err)
+	}
+	if response.Response == nil || response.Response.UID != request.Request.UID {
+		t.Fatalf("expected a response to request %s, got %+v", request.Request.UID, response.Response)
+	}
+	if response.APIVersion != request.APIVersion || response.Kind != request.Kind {
+		t.Errorf("expected a %s %s, got %s %s", request.APIVersion, request.Kind, response.APIVersion, response.Kind)
+	}
+	return &response
+}
+
+func TestAdmitsValidApp(t *testing.T) {
+	response := review(t, NewValidatingHandler(), "create-valid.json")
+	if !response.Response.Allowed {
+		t.Errorf("expected the app to be admitted, got %+v", response.Response.Result)
+	}
+}
+
+func TestDeniesInvalidApp(t *testing.T) {
+	response := review(t, NewValidatingHandler(), "create-invalid.json")
+	if response.Response.Allowed {
+		t.Fatalf("expected the app to be denied")
+	}
+	message := response.Response.Result.Message
+	for _, field := range []string{"spec.deployment.image", "spec.deployment.replicas", "spec.ingress.enabled"} {
+		if !strings.Contains(message, field) {
+			t.Errorf("expected %s to be reported, got %q", field, message)
+		}
+	}
+	if response.Response.Result.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected code %d, got %d", http.StatusUnprocessableEntity, response.Response.Result.Code)
+	}
+}
+
+func TestAdmitsComponents(t *testing.T) {
+	// a v2 app with a web and a worker component, converted to v1 before admission
+	response := review(t, NewValidatingHandler(), "create-components.json")
+	if !response.Response.Allowed {
+		t.Errorf("expected the app to be admitted, got %+v", response.Response.Result)
+	}
+}
+
+func TestDeniesInvalidComponent(t *testing.T) {
+	response := review(t, NewValidatingHandler(), "create-components-invalid.json")
+	if response.Response.Allowed {
+		t.Fatalf("expected the app to be denied")
+	}
+	message := response.Response.Result.Message
+	for _, field := range []string{"spec.components[1].workload.image", "spec.components[1].service.name", "spec.components[1].strategy.type"} {
+		if !strings.Contains(message, field) {
+			t.Errorf("expected %s to be reported, got %q", field, message)
+		}
+	}
+}
+
+func TestAdmitsRename(t *testing.T) {
+	response := review(t, NewValidatingHandler(), "update-rename.json")
+	if !response.Response.Allowed {
+		t.Errorf("expected the rename to be admitted, got %+v", response.Response.Result)
+	}
+}
+
+func TestDeniesRenameOfImmutableChildNames(t *testing.T) {
+	response := review(t, NewValidatingHandler(WithImmutableChildNames(true)), "update-rename.json")
+	if response.Response.Allowed {
+		t.Fatalf("expected the rename to be denied")
+	}
+	if message := response.Response.Result.Message; !strings.Contains(message, "spec.deployment.name") {
+		t.Errorf("expected spec.deployment.name to be reported, got %q", message)
+	}
+}
+
+func TestAdmitsMetadataUpdateOfInvalidApp(t *testing.T) {
+	// the controller releases its finalizer from an app admitted before the webhook existed
+	response := review(t, NewValidatingHandler(), "update-finalizer.json")
+	if !response.Response.Allowed {
+		t.Errorf("expected the finalizer update to be admitted, got %+v", response.Response.Result)
+	}
+}
+
+func TestRejectsMalformedReview(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewValidatingHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, ValidatePath, strings.NewReader("{")))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rec.Code)
+	}
+}
+
+// patchedSpec returns the spec a defaulting response replaces the spec of the app with
+func patchedSpec(t *testing.T, response *admission.AdmissionReview) appcontrollerv1.AppSpec {
+	if !response.Response.Allowed || response.Response.PatchType == nil || *response.Response.PatchType != admission.PatchTypeJSONPatch {
+		t.Fatalf("expected the app to be admitted with a json patch, got %+v", response.Response)
+	}
+	var patch []struct {
+		Op    string                  `json:"op"`
+		Path  string                  `json:"path"`
+		Value appcontrollerv1.AppSpec `json:"value"`
+	}
+	if err := json.Unmarshal(response.Response.Patch, &patch); err != nil {
+		t.Fatalf("decode patch: %v", err)
+	}
+	if len(patch) != 1 || patch[0].Op != "replace" || patch[0].Path != "/spec" {
+		t.Fatalf("expected the spec to be replaced, got %s", response.Response.Patch)
+	}
+	return patch[0].Value
+}
+
+func TestDefaultsMinimalApp(t *testing.T) {
+	spec := patchedSpec(t, review(t, NewMutatingHandler(), "create-minimal.json"))
+	if spec.Deployment.Name != "nginx-app-deploy" || spec.Service.Name != "nginx-app-svc" || spec.Ingress.Name != "nginx-app-ingress" {
+		t.Errorf("expected child names derived from the app name, got %s, %s and %s",
+			spec.Deployment.Name, spec.Service.Name, spec.Ingress.Name)
+	}
+	if spec.Deployment.WorkloadKind != appcontrollerv1.DeploymentWorkloadKind {
+		t.Errorf("expected a Deployment workload, got %q", spec.Deployment.WorkloadKind)
+	}
+	if spec.Deployment.ImagePullPolicy != core.PullIfNotPresent {
+		t.Errorf("expected IfNotPresent for a tagged image, got %s", spec.Deployment.ImagePullPolicy)
+	}
+	if port := spec.Service.Ports[0]; port.Protocol != core.ProtocolTCP || port.TargetPort != 8080 {
+		t.Errorf("expected TCP to 8080, got %+v", port)
+	}
+}
+
+func TestDefaultsKeepSetFields(t *testing.T) {
+	spec := patchedSpec(t, review(t, NewMutatingHandler(), "create-valid.json"))
+	if spec.Deployment.Name != "nginx-app-deploy" || spec.Deployment.Replicas != 3 {
+		t.Errorf("expected the deployment to be kept, got %+v", spec.Deployment)
+	}
+	if spec.Deployment.ImagePullPolicy != core.PullAlways {
+		t.Errorf("expected Always for a latest image, got %s", spec.Deployment.ImagePullPolicy)
+	}
+	if len(spec.Service.Ports) != 1 || spec.Service.Ports[0].Port != 80 {
+		t.Errorf("expected the default port 80, got %+v", spec.Service.Ports)
+	}
+}
+
+// convertReview posts the conversion review data to the conversion handler and returns its answer
+func convertReview(t *testing.T, data []byte) *conversionReview {
+	rec := httptest.NewRecorder()
+	NewConversionHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, ConvertPath, bytes.NewReader(data)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var response conversionReview
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode conversion review: %v", err)
+	}
+	if response.Response == nil {
+		t.Fatalf("expected a conversion response")
+	}
+	return &response
+}
+
+func TestConvertsAppsBetweenVersions(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "convert-v2-to-v1.json"))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	var request conversionReview
+	if err := json.Unmarshal(data, &request); err != nil {
+		t.Fatalf("decode fixture: %v", err)
+	}
+
+	response := convertReview(t, data)
+	if response.Response.UID != request.Request.UID || response.Response.Result.Status != metav1.StatusSuccess {
+		t.Fatalf("expected request %s to succeed, got %+v", request.Request.UID, response.Response)
+	}
+	if len(response.Response.ConvertedObjects) != 1 {
+		t.Fatalf("expected one converted object, got %d", len(response.Response.ConvertedObjects))
+	}
+	var hub appcontrollerv1.App
+	if err := json.Unmarshal(response.Response.ConvertedObjects[0].Raw, &hub); err != nil {
+		t.Fatalf("decode converted app: %v", err)
+	}
+	if hub.APIVersion != "appcontroller.me/v1" || hub.Spec.Deployment.Name != "shop-web" || hub.Status.ReadyReplicas != 2 {
+		t.Errorf("expected the web component as a v1 app, got %s with %+v", hub.APIVersion, hub.Spec.Deployment)
+	}
+
+	// converting back restores the worker component
+	request.Request.DesiredAPIVersion = "appcontroller.me/v2"
+	request.Request.Objects = response.Response.ConvertedObjects
+	data, err = json.Marshal(request)
+	if err != nil {
+		t.Fatalf("encode conversion review: %v", err)
+	}
+	response = convertReview(t, data)
+	if response.Response.Result.Status != metav1.StatusSuccess {
+		t.Fatalf("expected the conversion back to succeed, got %+v", response.Response.Result)
+	}
+	var app appcontrollerv2.App
+	if err := json.Unmarshal(response.Response.ConvertedObjects[0].Raw, &app); err != nil {
+		t.Fatalf("decode converted app: %v", err)
+	}
+	if len(app.Spec.Components) != 2 || app.Spec.Components[1].Workload.Image != "shop/worker:1.0" {
+		t.Errorf("expected the worker component to be restored, got %+v", app.Spec.Components)
+	}
+	if _, ok := app.Annotations[appcontrollerv2.ComponentsAnnotation]; ok {
+		t.Errorf("expected %s to be dropped from the v2 app", appcontrollerv2.ComponentsAnnotation)
+	}
+}
+
+func TestFailsUnsupportedConversion(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "convert-v2-to-v1.json"))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	data = bytes.Replace(data, []byte(`"desiredAPIVersion": "appcontroller.me/v1"`), []byte(`"desiredAPIVersion": "appcontroller.me/v3"`), 1)
+
+	response := convertReview(t, data)
+	if response.Response.Result.Status != metav1.StatusFailure || len(response.Response.ConvertedObjects) != 0 {
+		t.Errorf("expected the conversion to v3 to fail, got %+v", response.Response)
+	}
+}
@@ -32,8 +32,11 @@ type MyAppSpec struct {
 	Name string `json:"name"`
 	// app image name, e.g.: nginx:latest
 	Image string `json:"image"`
-	// app deploy replications, default: 3
-	Replicas int32 `json:"replicas"`
+	// app deploy replications, 0 scales the app down, default: 3
+	//+optional
+	//+kubebuilder:default=3
+	//+kubebuilder:validation:Minimum=0
+	Replicas *int32 `json:"replicas,omitempty"`
 	// enabled app service
 	EnabledService bool `json:"enabledService"`
 	// enabled app ingress
@@ -0,0 +1,51 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// DefaultReplicas is the number of pods of a MyApp not setting spec.replicas
+const DefaultReplicas int32 = 3
+
+// log is for logging in this package.
+var myapplog = logf.Log.WithName("myapp-resource")
+
+func (r *MyApp) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-mykb-me-my-domain-v1-myapp,mutating=true,failurePolicy=fail,sideEffects=None,groups=mykb.me.my.domain,resources=myapps,verbs=create;update,versions=v1,name=mmyapp.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &MyApp{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *MyApp) Default() {
+	myapplog.Info("default", "name", r.Name)
+
+	// the schema only defaults an omitted field, requests skipping it still get the default.
+	// an explicit 0 is kept to scale the app down
+	if r.Spec.Replicas == nil {
+		replicas := DefaultReplicas
+		r.Spec.Replicas = &replicas
+	}
+}
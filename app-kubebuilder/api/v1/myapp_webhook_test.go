@@ -0,0 +1,38 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import "testing"
+
+func TestDefault(t *testing.T) {
+	zero, five := int32(0), int32(5)
+	for _, tc := range []struct {
+		name     string
+		replicas *int32
+		want     int32
+	}{
+		{"unset", nil, DefaultReplicas},
+		{"scaled down", &zero, 0},
+		{"set", &five, 5},
+	} {
+		app := &MyApp{Spec: MyAppSpec{Name: "nginx", Image: "nginx:latest", Replicas: tc.replicas}}
+		app.Default()
+		if app.Spec.Replicas == nil || *app.Spec.Replicas != tc.want {
+			t.Errorf("%s: expected %d replicas, got %v", tc.name, tc.want, app.Spec.Replicas)
+		}
+	}
+}
@@ -9,9 +9,13 @@
 package main
 
 import (
+	"flag"
 	"log"
 
 	"github.com/istudies/k8s-operator/builtin-k8s-controller/pkg"
+	"github.com/istudies/k8s-operator/builtin-k8s-controller/pkg/metrics"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -19,6 +23,11 @@ import (
 )
 
 func main() {
+	var metricsAddr string
+	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080",
+		"The address the metric endpoint binds to. Set to 0 to disable it.")
+	flag.Parse()
+
 	config, err := clientcmd.BuildConfigFromFlags("", clientcmd.RecommendedHomeFile)
 	if err != nil {
 		config, err = rest.InClusterConfig()
@@ -55,6 +64,29 @@ func main() {
 
 	ctl := pkg.NewController(clientset, factory.Core().V1().Services(), factory.Networking().V1().Ingresses())
 
+	// metrics
+	ingressLister := factory.Networking().V1().Ingresses().Lister()
+	metrics.RegisterManagedObjects("builtin_controller_managed_ingresses", "Number of Ingresses created for annotated Services", func() int {
+		ingresses, err := ingressLister.List(labels.Everything())
+		if err != nil {
+			return 0
+		}
+		count := 0
+		for _, ingress := range ingresses {
+			if ownerReference := v1.GetControllerOf(ingress); ownerReference != nil && ownerReference.Kind == "Service" {
+				count++
+			}
+		}
+		return count
+	})
+	if metricsAddr != "0" {
+		go func() {
+			if err := metrics.Serve(metricsAddr); err != nil {
+				log.Fatalln(err)
+			}
+		}()
+	}
+
 	stopCh := make(chan struct{})
 	factory.Start(stopCh)
 	factory.WaitForCacheSync(stopCh)
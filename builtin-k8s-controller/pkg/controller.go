@@ -15,6 +15,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/istudies/k8s-operator/builtin-k8s-controller/pkg/metrics"
 	core "k8s.io/api/core/v1"
 	ing "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -32,6 +33,8 @@ import (
 
 const (
 	workNum = 5
+	// controllerName labels the metrics of this controller
+	controllerName = "ingress"
 )
 
 var once sync.Once
@@ -106,10 +109,14 @@ func (c *controller) workProcessNextKey() bool {
 		return false
 	}
 	defer c.queue.Done(key)
+	start := time.Now()
 	err := c.syncService(key.(string))
+	metrics.ReconcileTime.WithLabelValues(controllerName).Observe(time.Since(start).Seconds())
 	if err != nil {
-		c.handleErr(key.(string), err)
+		metrics.ReconcileTotal.WithLabelValues(controllerName, c.handleErr(key.(string), err)).Inc()
+		return true
 	}
+	metrics.ReconcileTotal.WithLabelValues(controllerName, metrics.ResultSuccess).Inc()
 	return true
 }
 
@@ -152,13 +159,14 @@ func (c *controller) syncService(key string) error {
 	return nil
 }
 
-func (c *controller) handleErr(key string, err error) {
+func (c *controller) handleErr(key string, err error) string {
 	if c.queue.NumRequeues(key) <= 10 {
 		c.queue.AddRateLimited(key)
-		return
+		return metrics.ResultError
 	}
 	c.queue.Forget(key)
 	runtime.HandleError(err)
+	return metrics.ResultDropped
 }
 
 func (c *controller) constructIngress(service *core.Service) *ing.Ingress {